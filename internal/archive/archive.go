@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/database"
+)
+
+// Tables lists the org tables which are exported, in an order which respects foreign keys.
+var Tables = []string{"Customers", "Products", "Orders"}
+
+// Archive is a full export of an org's data.
+type Archive struct {
+	Org        string
+	ExportedAt time.Time
+	Tables     map[string][]map[string]any
+}
+
+// Export reads every row of tables from db into an Archive.
+func Export(ctx context.Context, db *sql.DB, org string, tables ...string) (*Archive, error) {
+	a := &Archive{
+		Org:        org,
+		ExportedAt: time.Now().UTC(),
+		Tables:     map[string][]map[string]any{},
+	}
+
+	for _, table := range tables {
+		rows, err := exportTable(ctx, db, table)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to export table %s", table)
+		}
+		a.Tables[table] = rows
+	}
+
+	return a, nil
+}
+
+func exportTable(ctx context.Context, db *sql.DB, table string) ([]map[string]any, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+database.DialectOf(db).QuoteIdent(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	out := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			// the drivers return text columns as raw bytes, which would otherwise be base64
+			// encoded by encoding/json.
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+				continue
+			}
+			row[column] = values[i]
+		}
+		out = append(out, row)
+	}
+
+	return out, rows.Err()
+}
+
+// Save writes the archive to path as indented JSON. It refuses to overwrite an existing file.
+func (a *Archive) Save(path string) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal archive")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create archive %s", path)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write archive %s", path)
+	}
+
+	return errors.Wrapf(f.Close(), "failed to close archive %s", path)
+}
+
+// Load reads an archive previously written by Save.
+func Load(path string) (*Archive, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	var a Archive
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal archive")
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,49 @@
+package archive
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSaveLoadArchive(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/microsoft.json"
+
+	in := Archive{
+		Org:        "microsoft",
+		ExportedAt: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+		Tables: map[string][]map[string]any{
+			"Customers": {
+				{"ID": float64(1), "email": "foo@bar.com", "state": "WA"},
+			},
+			"Products": {},
+		},
+	}
+
+	if err := in.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.DeepEqual(t, in, *out)
+}
+
+func TestSaveArchive_willNotOverwriteExistingArchive(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/microsoft.json"
+
+	a := Archive{Org: "microsoft"}
+	if err := a.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorContains(t, a.Save(path), "failed to create archive")
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultAuditLogPath = "org_audit.log"
+
+// Record is a single entry in the audit log. The log holds one JSON encoded Record per line.
+type Record struct {
+	Action  string
+	Org     string
+	Actor   string
+	At      time.Time
+	Details map[string]string `json:",omitempty"`
+}
+
+// Actor returns the name of the user running the current process.
+func Actor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// Append adds r to the end of the audit log at path, creating the log if necessary.
+func Append(path string, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal audit record")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open audit log %s", path)
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write audit log %s", path)
+	}
+
+	return errors.Wrapf(f.Close(), "failed to close audit log %s", path)
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAppend_appendsOneRecordPerLine(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/org_audit.log"
+	at := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	in := []Record{
+		{Action: "create-org", Org: "microsoft", Actor: "alice", At: at},
+		{Action: "delete-org", Org: "microsoft", Actor: "bob", At: at, Details: map[string]string{"archive": "microsoft.json"}},
+	}
+	for _, r := range in {
+		if err := Append(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var out []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+
+	assert.DeepEqual(t, in, out)
+}
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultConfigPath = "store.json"
+
+// Environment variables read by Load. They take precedence over the config file, and are
+// overridden in turn by command line flags.
+const (
+	EnvConfig         = "STORE_CONFIG"
+	EnvDBUser         = "STORE_DB_USER"
+	EnvDBPassword     = "STORE_DB_PASSWORD"
+	EnvDBPasswordFile = "STORE_DB_PASSWORD_FILE"
+	EnvDBHost         = "STORE_DB_HOST"
+	EnvDBPort         = "STORE_DB_PORT"
+	EnvDBDriver       = "STORE_DB_DRIVER"
+	EnvDBPath         = "STORE_DB_PATH"
+)
+
+type Config struct {
+	Database Database
+
+	// Orgs holds per-org connection settings, for orgs which do not live on the default server.
+	Orgs map[string]*OrgConnection
+}
+
+// OrgConnection describes where an org's database lives. Unset fields inherit from the default
+// Database settings.
+type OrgConnection struct {
+	Host      string
+	Port      int
+	User      string
+	SecretRef string
+	Database  string
+	TLS       *TLS
+
+	// Replicas serve read-only queries, as long as they are no more than MaxReplicaLag behind
+	// the primary.
+	Replicas      []*Replica
+	MaxReplicaLag Duration
+}
+
+// Replica describes a read replica of an org's database. Unset fields inherit from the org's
+// primary connection.
+type Replica struct {
+	Host      string
+	Port      int
+	User      string
+	SecretRef string
+	TLS       *TLS
+}
+
+// TLS modes, from least to most strict.
+const (
+	// TLSModeDisabled never uses TLS. It is the default.
+	TLSModeDisabled = "disabled"
+	// TLSModePreferred uses TLS when the server supports it, without verifying the server.
+	TLSModePreferred = "preferred"
+	// TLSModeRequired always uses TLS, without verifying the server.
+	TLSModeRequired = "required"
+	// TLSModeVerifyCA always uses TLS and verifies the server certificate against CAFile, or the
+	// system roots if CAFile is unset, without checking the host name.
+	TLSModeVerifyCA = "verify-ca"
+	// TLSModeVerifyIdentity is TLSModeVerifyCA plus checking the host name.
+	TLSModeVerifyIdentity = "verify-identity"
+)
+
+// TLS holds the TLS settings for a database connection. CertFile and KeyFile set a client
+// certificate.
+type TLS struct {
+	Mode     string
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Database drivers.
+const (
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Database holds the components of the database DSN. Password is never printed by String, so a
+// Database is safe to log.
+//
+// With the sqlite driver, only Path and Name are used: each database is the file Name.db in the
+// directory Path.
+type Database struct {
+	Driver       string
+	Path         string
+	User         string
+	Password     string
+	PasswordFile string
+	Host         string
+	Port         int
+	Name         string
+	TLS          TLS
+	Pool         Pool
+}
+
+// Pool holds the connect retry, connection pool and timeout settings for a database.
+type Pool struct {
+	// ConnectRetries is the number of times a failed connection attempt is retried, waiting
+	// RetryBackoff before the first retry and doubling the wait up to MaxRetryBackoff.
+	ConnectRetries  int
+	RetryBackoff    Duration
+	MaxRetryBackoff Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime Duration
+
+	DialTimeout  Duration
+	ReadTimeout  Duration
+	WriteTimeout Duration
+}
+
+// Duration is a time.Duration which is read from and written to JSON as a string like "5s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrapf(err, "duration must be a string like \"5s\"")
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Database) String() string {
+	password := ""
+	if d.Password != "" {
+		password = "<redacted>"
+	}
+	return fmt.Sprintf("{Driver:%s Path:%s User:%s Password:%s PasswordFile:%s Host:%s Port:%d Name:%s TLS:%+v}", d.Driver, d.Path, d.User, password, d.PasswordFile, d.Host, d.Port, d.Name, d.TLS)
+}
+
+// Addr returns the host:port address of the database server.
+func (d Database) Addr() string {
+	return fmt.Sprintf("%s:%d", d.Host, d.Port)
+}
+
+// SetPassword sets the password directly, replacing any password file set by a lower layer.
+func (d *Database) SetPassword(password string) {
+	d.Password = password
+	d.PasswordFile = ""
+}
+
+// SetPasswordFile reads the password from path, replacing any password set by a lower layer.
+func (d *Database) SetPasswordFile(path string) {
+	d.Password = ""
+	d.PasswordFile = path
+}
+
+// ResolvePassword loads the password from PasswordFile, if one is set.
+func (d *Database) ResolvePassword() error {
+	if d.PasswordFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(d.PasswordFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read password file %s", d.PasswordFile)
+	}
+
+	d.Password = strings.TrimRight(string(data), "\r\n")
+	return nil
+}
+
+// ResolveSecret returns the secret referenced by ref, which is either "env:NAME" to read an
+// environment variable or "file:PATH" to read a file.
+func ResolveSecret(ref string, lookupEnv func(string) (string, bool)) (string, error) {
+	kind, value, ok := strings.Cut(ref, ":")
+	if !ok {
+		return "", errors.Errorf("invalid secret reference %q, must be env:NAME or file:PATH", ref)
+	}
+
+	switch kind {
+	case "env":
+		secret, ok := lookupEnv(value)
+		if !ok {
+			return "", errors.Errorf("environment variable %s is not set", value)
+		}
+		return secret, nil
+	case "file":
+		data, err := ioutil.ReadFile(value)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read secret file %s", value)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return "", errors.Errorf("invalid secret reference %q, must be env:NAME or file:PATH", ref)
+	}
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() *Config {
+	return &Config{
+		Database: Database{
+			Driver:   DriverMySQL,
+			Path:     ".",
+			User:     "admin",
+			Password: "password123",
+			Host:     "localhost",
+			Port:     3306,
+			Pool: Pool{
+				ConnectRetries:  3,
+				RetryBackoff:    Duration(200 * time.Millisecond),
+				MaxRetryBackoff: Duration(5 * time.Second),
+				MaxOpenConns:    10,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: Duration(5 * time.Minute),
+				DialTimeout:     Duration(5 * time.Second),
+				ReadTimeout:     Duration(30 * time.Second),
+				WriteTimeout:    Duration(30 * time.Second),
+			},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the config file at path, then the
+// environment. A missing file is only an error if required is set.
+func Load(path string, required bool, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	if err := cfg.mergeFile(path, required); err != nil {
+		return nil, err
+	}
+	if err := cfg.mergeEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// file mirrors Config with pointer fields, so that unset values can be told apart from zero
+// values.
+type file struct {
+	Database struct {
+		Driver       *string
+		Path         *string
+		User         *string
+		Password     *string
+		PasswordFile *string
+		Host         *string
+		Port         *int
+		TLS          *TLS
+		// Pool is decoded over the current settings, so unset fields keep their values.
+		Pool json.RawMessage
+	}
+	Orgs map[string]*OrgConnection
+}
+
+func (c *Config) mergeFile(path string, required bool) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to read config %s", path)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal config %s", path)
+	}
+
+	db := f.Database
+	if db.Driver != nil {
+		c.Database.Driver = *db.Driver
+	}
+	if db.Path != nil {
+		c.Database.Path = *db.Path
+	}
+	if db.User != nil {
+		c.Database.User = *db.User
+	}
+	if db.Password != nil {
+		c.Database.SetPassword(*db.Password)
+	}
+	if db.PasswordFile != nil {
+		c.Database.SetPasswordFile(*db.PasswordFile)
+	}
+	if db.Host != nil {
+		c.Database.Host = *db.Host
+	}
+	if db.Port != nil {
+		c.Database.Port = *db.Port
+	}
+	if db.TLS != nil {
+		c.Database.TLS = *db.TLS
+	}
+	if db.Pool != nil {
+		if err := json.Unmarshal(db.Pool, &c.Database.Pool); err != nil {
+			return errors.Wrapf(err, "failed to unmarshal pool settings in config %s", path)
+		}
+	}
+	c.Orgs = f.Orgs
+
+	return nil
+}
+
+func (c *Config) mergeEnv(lookupEnv func(string) (string, bool)) error {
+	if v, ok := lookupEnv(EnvDBDriver); ok {
+		c.Database.Driver = v
+	}
+	if v, ok := lookupEnv(EnvDBPath); ok {
+		c.Database.Path = v
+	}
+	if v, ok := lookupEnv(EnvDBUser); ok {
+		c.Database.User = v
+	}
+	if v, ok := lookupEnv(EnvDBPassword); ok {
+		c.Database.SetPassword(v)
+	}
+	if v, ok := lookupEnv(EnvDBPasswordFile); ok {
+		c.Database.SetPasswordFile(v)
+	}
+	if v, ok := lookupEnv(EnvDBHost); ok {
+		c.Database.Host = v
+	}
+	if v, ok := lookupEnv(EnvDBPort); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", EnvDBPort)
+		}
+		c.Database.Port = port
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestLoad_noFileOrEnv_returnsDefaults(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(t.TempDir()+"/store.json", false, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.DeepEqual(t, cfg, Default())
+}
+
+func TestLoad_requiredFileIsMissing_returnsAnError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Load(t.TempDir()+"/store.json", true, env(nil))
+	assert.ErrorContains(t, err, "failed to read config")
+}
+
+func TestLoad_envOverridesFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := dir + "/store.json"
+	data := `{"Database": {"User": "file-user", "Password": "file-password", "Host": "db.internal", "Port": 3307}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dir+"/password", []byte("env-password\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, true, env(map[string]string{
+		EnvDBUser:         "env-user",
+		EnvDBPasswordFile: dir + "/password",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, cfg.Database.ResolvePassword())
+
+	assert.DeepEqual(t, cfg.Database, Database{
+		Driver:       DriverMySQL,
+		Path:         ".",
+		User:         "env-user",
+		Password:     "env-password",
+		PasswordFile: dir + "/password",
+		Host:         "db.internal",
+		Port:         3307,
+		Pool:         Default().Database.Pool,
+	})
+	assert.Equal(t, cfg.Database.Addr(), "db.internal:3307")
+}
+
+func TestLoad_invalidPort_returnsAnError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Load(t.TempDir()+"/store.json", false, env(map[string]string{EnvDBPort: "abc"}))
+	assert.ErrorContains(t, err, "invalid "+EnvDBPort)
+}
+
+func TestDatabase_neverPrintsPassword(t *testing.T) {
+	t.Parallel()
+
+	db := Database{User: "admin", Password: "hunter2", Host: "localhost", Port: 3306}
+
+	for _, s := range []string{fmt.Sprint(db), fmt.Sprintf("%v", db), fmt.Sprintf("%+v", &db)} {
+		assert.Assert(t, !strings.Contains(s, "hunter2"), s)
+	}
+}
+
+func TestLoad_readsOrgConnections(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/store.json"
+	data := `{"Orgs": {"google": {"Host": "google.db.internal", "SecretRef": "env:GOOGLE_DB_PASSWORD", "TLS": {"Mode": "required"}}}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, true, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.DeepEqual(t, cfg.Orgs, map[string]*OrgConnection{
+		"google": {
+			Host:      "google.db.internal",
+			SecretRef: "env:GOOGLE_DB_PASSWORD",
+			TLS:       &TLS{Mode: "required"},
+		},
+	})
+}
+
+func TestLoad_readsOrgReplicas(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/store.json"
+	data := `{"Orgs": {"google": {"Replicas": [{"Host": "google-replica.db.internal", "User": "reader"}], "MaxReplicaLag": "10s"}}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, true, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.DeepEqual(t, cfg.Orgs["google"], &OrgConnection{
+		Replicas:      []*Replica{{Host: "google-replica.db.internal", User: "reader"}},
+		MaxReplicaLag: Duration(10 * time.Second),
+	})
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/secret"
+	if err := ioutil.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lookupEnv := env(map[string]string{"DB_PASSWORD": "from-env"})
+
+	secret, err := ResolveSecret("env:DB_PASSWORD", lookupEnv)
+	assert.NilError(t, err)
+	assert.Equal(t, secret, "from-env")
+
+	secret, err = ResolveSecret("file:"+path, lookupEnv)
+	assert.NilError(t, err)
+	assert.Equal(t, secret, "from-file")
+
+	_, err = ResolveSecret("env:MISSING", lookupEnv)
+	assert.ErrorContains(t, err, "MISSING is not set")
+
+	_, err = ResolveSecret("password123", lookupEnv)
+	assert.ErrorContains(t, err, "invalid secret reference")
+}
+
+func TestLoad_poolSettingsAreMergedOverDefaults(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/store.json"
+	data := `{"Database": {"Pool": {"ConnectRetries": 10, "DialTimeout": "1s"}}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, true, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default().Database.Pool
+	want.ConnectRetries = 10
+	want.DialTimeout = Duration(time.Second)
+	assert.DeepEqual(t, cfg.Database.Pool, want)
+}
+
+func TestLoad_invalidDuration_returnsAnError(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/store.json"
+	if err := ioutil.WriteFile(path, []byte(`{"Database": {"Pool": {"DialTimeout": 5}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path, true, env(nil))
+	assert.ErrorContains(t, err, "failed to unmarshal pool settings")
+}
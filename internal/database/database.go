@@ -0,0 +1,287 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// ErrTLSHandshake is returned by Open when a TLS connection is required but cannot be
+// established.
+var ErrTLSHandshake = errors.New("tls handshake failed")
+
+// ErrDatabaseNotFound is returned by Open when the database does not exist.
+var ErrDatabaseNotFound = errors.New("database not found")
+
+const mysqlErrUnknownDatabase = 1049
+
+// after is replaced in tests.
+var after = time.After
+
+// Open connects to the database described by conn, retrying transient failures, and applies the
+// pool settings to the returned *sql.DB.
+func Open(ctx context.Context, conn config.Database) (*sql.DB, error) {
+	switch conn.Driver {
+	case "", config.DriverMySQL:
+		return openMySQL(ctx, conn)
+	case config.DriverSQLite:
+		return openSQLite(ctx, conn)
+	case config.DriverPostgres:
+		return openPostgres(ctx, conn)
+	default:
+		return nil, errors.Errorf("unknown database driver %q, must be %s, %s or %s", conn.Driver, config.DriverMySQL, config.DriverSQLite, config.DriverPostgres)
+	}
+}
+
+// DialectFor returns the dialect of the named driver, one of the config.Driver constants.
+func DialectFor(driverName string) Dialect {
+	switch driverName {
+	case config.DriverSQLite:
+		return SQLite
+	case config.DriverPostgres:
+		return Postgres
+	default:
+		return MySQL
+	}
+}
+
+// SQLiteFile returns the path of the file holding a sqlite database.
+func SQLiteFile(conn config.Database) string {
+	return filepath.Join(conn.Path, conn.Name+".db")
+}
+
+// sqliteDriver is the sqlite3 driver with the regexp function behind the REGEXP operator, which
+// SQLite leaves to the application, and with LIKE comparing case as on the other databases.
+const sqliteDriver = "sqlite3_regexp"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if _, err := conn.Exec("PRAGMA case_sensitive_like = ON", nil); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("regexp", sqliteRegexp, true)
+		},
+	})
+}
+
+// sqliteRegexp implements "s REGEXP pattern". Patterns are cached since the function is called
+// once per row.
+func sqliteRegexp(pattern, s string) (bool, error) {
+	sqliteRegexps.Lock()
+	defer sqliteRegexps.Unlock()
+
+	re, ok := sqliteRegexps.cache[pattern]
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, err
+		}
+		if len(sqliteRegexps.cache) >= 64 {
+			sqliteRegexps.cache = map[string]*regexp.Regexp{}
+		}
+		sqliteRegexps.cache[pattern] = re
+	}
+	return re.MatchString(s), nil
+}
+
+var sqliteRegexps = struct {
+	sync.Mutex
+	cache map[string]*regexp.Regexp
+}{cache: map[string]*regexp.Regexp{}}
+
+func openSQLite(ctx context.Context, conn config.Database) (*sql.DB, error) {
+	if conn.Name == "" {
+		return nil, errors.New("sqlite connections require a database name")
+	}
+
+	if _, err := os.Stat(SQLiteFile(conn)); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, SQLiteFile(conn))
+	}
+
+	// mode=rw refuses to create missing databases, which must be created with Create.
+	dsn := fmt.Sprintf("file:%s?mode=rw&_foreign_keys=on&_busy_timeout=%d", SQLiteFile(conn), time.Duration(conn.Pool.WriteTimeout).Milliseconds())
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(conn.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(conn.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(conn.Pool.ConnMaxLifetime))
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "failed to open sqlite database %s", SQLiteFile(conn))
+	}
+
+	return db, nil
+}
+
+// Create creates the database described by conn, failing if it already exists.
+func Create(ctx context.Context, conn config.Database) error {
+	if conn.Driver == config.DriverSQLite {
+		f, err := os.OpenFile(SQLiteFile(conn), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create sqlite database")
+		}
+		return f.Close()
+	}
+
+	return execOnServer(ctx, conn, "CREATE DATABASE "+DialectFor(conn.Driver).QuoteIdent(conn.Name))
+}
+
+// Drop deletes the database described by conn and all of its data.
+func Drop(ctx context.Context, conn config.Database) error {
+	if conn.Driver == config.DriverSQLite {
+		return errors.Wrapf(os.Remove(SQLiteFile(conn)), "failed to drop sqlite database")
+	}
+
+	return execOnServer(ctx, conn, "DROP DATABASE "+DialectFor(conn.Driver).QuoteIdent(conn.Name))
+}
+
+// execOnServer runs statement on the database server without selecting a database.
+func execOnServer(ctx context.Context, conn config.Database, statement string) error {
+	server := conn
+	server.Name = ""
+	db, err := Open(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, statement)
+	return err
+}
+
+func openMySQL(ctx context.Context, conn config.Database) (*sql.DB, error) {
+	tlsConfig, err := tlsParam(conn, mysql.RegisterTLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := conn.Pool
+	cfg := mysql.Config{
+		User:         conn.User,
+		Passwd:       conn.Password,
+		Net:          "tcp",
+		Addr:         conn.Addr(),
+		DBName:       conn.Name,
+		TLSConfig:    tlsConfig,
+		Timeout:      time.Duration(pool.DialTimeout),
+		ReadTimeout:  time.Duration(pool.ReadTimeout),
+		WriteTimeout: time.Duration(pool.WriteTimeout),
+		// Scan DATETIME columns into time.Time, as the other drivers do.
+		ParseTime: true,
+	}
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(pool.ConnMaxLifetime))
+
+	err = retry(ctx, pool, func() error { return db.PingContext(ctx) })
+	if err != nil {
+		db.Close()
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrUnknownDatabase {
+			return nil, fmt.Errorf("%w: %v", ErrDatabaseNotFound, err)
+		}
+		if requiresTLS(conn.TLS.Mode) && isTLSError(err) {
+			return nil, fmt.Errorf("%w: connecting to %s with tls mode %s: %v", ErrTLSHandshake, conn.Addr(), conn.TLS.Mode, err)
+		}
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// retry calls fn until it succeeds, returns a permanent error or runs out of retries, backing
+// off exponentially between attempts. It gives up with ctx's error once ctx is done.
+func retry(ctx context.Context, pool config.Pool, fn func() error) error {
+	backoff := time.Duration(pool.RetryBackoff)
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= pool.ConnectRetries || isPermanent(err) {
+			return err
+		}
+
+		log.Printf("failed to connect to database (attempt %d of %d), retrying in %s: %v", attempt+1, pool.ConnectRetries+1, backoff, err)
+		select {
+		case <-after(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if max := time.Duration(pool.MaxRetryBackoff); max > 0 && backoff > max {
+			backoff = max
+		}
+	}
+}
+
+// isPermanent reports whether err will not go away by retrying, such as a failed TLS handshake or
+// a server error, like rejected credentials, which is not on the transient lists below.
+func isPermanent(err error) bool {
+	var (
+		mysqlErr *mysql.MySQLError
+		pqErr    *pq.Error
+	)
+	switch {
+	case errors.As(err, &mysqlErr):
+		return !mysqlTransientErrors[mysqlErr.Number]
+	case errors.As(err, &pqErr):
+		return !pgTransientErrors[pqErr.Code] && pqErr.Code.Class() != pgConnectionExceptionClass
+	default:
+		return isTLSError(err)
+	}
+}
+
+// mysqlTransientErrors are the MySQL server errors worth retrying.
+var mysqlTransientErrors = map[uint16]bool{
+	1040: true, // too many connections
+	1053: true, // server shutdown in progress
+	1203: true, // user has too many connections
+	1205: true, // lock wait timeout
+	1213: true, // deadlock
+}
+
+// pgTransientErrors are the Postgres server errors worth retrying, besides the connection
+// exceptions.
+var pgTransientErrors = map[pq.ErrorCode]bool{
+	"53300": true, // too many connections
+	"57P03": true, // cannot connect now, such as while the server starts
+}
+
+const pgConnectionExceptionClass = "08"
+
+func isTLSError(err error) bool {
+	var (
+		recordErr   tls.RecordHeaderError
+		unknownAuth x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidCert x509.CertificateInvalidError
+	)
+
+	return errors.Is(err, mysql.ErrNoTLS) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &unknownAuth) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidCert)
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+	"gotest.tools/v3/assert"
+)
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	var slept []time.Duration
+	after = func(d time.Duration) <-chan time.Time {
+		slept = append(slept, d)
+		return time.After(0)
+	}
+	defer func() { after = time.After }()
+
+	pool := config.Pool{
+		ConnectRetries:  4,
+		RetryBackoff:    config.Duration(100 * time.Millisecond),
+		MaxRetryBackoff: config.Duration(300 * time.Millisecond),
+	}
+
+	t.Run("retries transient errors with backoff", func(t *testing.T) {
+		slept = nil
+		calls := 0
+		err := retry(ctx, pool, func() error {
+			calls++
+			return errors.New("connection refused")
+		})
+
+		assert.ErrorContains(t, err, "connection refused")
+		assert.Equal(t, calls, 5)
+		assert.DeepEqual(t, slept, []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			300 * time.Millisecond,
+			300 * time.Millisecond,
+		})
+	})
+
+	t.Run("stops once the call succeeds", func(t *testing.T) {
+		slept = nil
+		calls := 0
+		err := retry(ctx, pool, func() error {
+			calls++
+			if calls < 2 {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+
+		assert.NilError(t, err)
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		slept = nil
+		calls := 0
+		err := retry(ctx, pool, func() error {
+			calls++
+			return &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+		})
+
+		assert.ErrorContains(t, err, "Access denied")
+		assert.Equal(t, calls, 1)
+		assert.Equal(t, len(slept), 0)
+	})
+
+	t.Run("retries transient server errors", func(t *testing.T) {
+		calls := 0
+		err := retry(ctx, pool, func() error {
+			calls++
+			if calls < 3 {
+				return &mysql.MySQLError{Number: 1040, Message: "Too many connections"}
+			}
+			return nil
+		})
+
+		assert.NilError(t, err)
+		assert.Equal(t, calls, 3)
+	})
+
+	t.Run("stops waiting once the context is done", func(t *testing.T) {
+		after = func(time.Duration) <-chan time.Time { return nil }
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		calls := 0
+		err := retry(ctx, pool, func() error {
+			calls++
+			return errors.New("connection refused")
+		})
+
+		assert.Assert(t, errors.Is(err, context.Canceled), err)
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection refused"), false},
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied"}, true},
+		{&mysql.MySQLError{Number: 1049, Message: "Unknown database"}, true},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, false},
+		{&pq.Error{Code: "28P01"}, true},
+		{&pq.Error{Code: "57P03"}, false},
+		{&pq.Error{Code: "08006"}, false},
+		{mysql.ErrNoTLS, true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, isPermanent(test.err), test.want, test.err.Error())
+	}
+}
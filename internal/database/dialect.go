@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// Dialect hides the differences between the SQL understood by each database. Queries are
+// written with ? placeholders and passed through Rebind before they are run.
+type Dialect interface {
+	// Name is the driver name, which is also the suffix of per-dialect migration files such as
+	// initial_0000.sqlite.sql.
+	Name() string
+	// Concat returns an expression concatenating exprs.
+	Concat(exprs ...string) string
+	// Like returns a condition which is true when expr matches the LIKE pattern in the ?
+	// placeholder, with escape as the pattern's escape character.
+	Like(expr string, escape rune) string
+	// Regexp returns a condition which is true when expr contains a match of the regular
+	// expression in the ? placeholder.
+	Regexp(expr string) string
+	// Rebind rewrites the ? placeholders in query into the dialect's placeholder syntax.
+	Rebind(query string) string
+	// QuoteIdent quotes an identifier such as a table or column name.
+	QuoteIdent(name string) string
+	// ReturningID returns the clause appended to an INSERT to return the id of the new row, or
+	// "" if the driver supports LastInsertId instead.
+	ReturningID() string
+	// ResetTable returns the statements which delete every row in table and restart its ids.
+	ResetTable(table string) []string
+}
+
+var (
+	MySQL    Dialect = mysqlDialect{}
+	SQLite   Dialect = sqliteDialect{}
+	Postgres Dialect = postgresDialect{}
+)
+
+// like returns a standard LIKE condition with escape as its escape character.
+func like(expr string, escape rune) string {
+	return expr + " LIKE ? ESCAPE '" + strings.ReplaceAll(string(escape), "'", "''") + "'"
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return config.DriverMySQL }
+
+func (mysqlDialect) Concat(exprs ...string) string {
+	return "CONCAT(" + strings.Join(exprs, ", ") + ")"
+}
+
+// Like and Regexp compare case, like every other comparison of text, because the
+// caseSensitiveText_0002 migration gives text columns the binary utf8mb4_bin collation.
+func (mysqlDialect) Like(expr string, escape rune) string {
+	// Backslashes and quotes are also escapes inside MySQL string literals.
+	literal := string(escape)
+	if escape == '\\' || escape == '\'' {
+		literal = "\\" + literal
+	}
+	return expr + " LIKE ? ESCAPE '" + literal + "'"
+}
+
+func (mysqlDialect) Regexp(expr string) string { return expr + " REGEXP ?" }
+
+func (mysqlDialect) Rebind(query string) string { return query }
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (mysqlDialect) ReturningID() string { return "" }
+
+func (d mysqlDialect) ResetTable(table string) []string {
+	return []string{
+		"DELETE FROM " + d.QuoteIdent(table),
+		"ALTER TABLE " + d.QuoteIdent(table) + " AUTO_INCREMENT=1",
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return config.DriverSQLite }
+
+func (sqliteDialect) Concat(exprs ...string) string {
+	return "(" + strings.Join(exprs, " || ") + ")"
+}
+
+// Like relies on the case_sensitive_like pragma which Open sets for SQLite connections.
+func (sqliteDialect) Like(expr string, escape rune) string { return like(expr, escape) }
+
+// Regexp relies on the regexp function which Open registers for SQLite connections.
+func (sqliteDialect) Regexp(expr string) string { return expr + " REGEXP ?" }
+
+func (sqliteDialect) Rebind(query string) string { return query }
+
+func (sqliteDialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) ReturningID() string { return "" }
+
+func (d sqliteDialect) ResetTable(table string) []string {
+	return []string{
+		"DELETE FROM " + d.QuoteIdent(table),
+		fmt.Sprintf("DELETE FROM sqlite_sequence WHERE name = '%s'", strings.ReplaceAll(table, "'", "''")),
+	}
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return config.DriverPostgres }
+
+func (postgresDialect) Concat(exprs ...string) string {
+	return "(" + strings.Join(exprs, " || ") + ")"
+}
+
+func (postgresDialect) Like(expr string, escape rune) string { return like(expr, escape) }
+
+// Regexp matches with the ~ operator.
+func (postgresDialect) Regexp(expr string) string { return expr + " ~ ?" }
+
+// Rebind numbers the ? placeholders in query as $1, $2, ..., skipping any inside quoted strings
+// and identifiers.
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// QuoteIdent lowercases name before quoting it. Postgres folds unquoted identifiers to lowercase,
+// so this refers to the same object as the unquoted name used everywhere else.
+func (postgresDialect) QuoteIdent(name string) string {
+	return pq.QuoteIdentifier(strings.ToLower(name))
+}
+
+func (postgresDialect) ReturningID() string { return " RETURNING id" }
+
+func (d postgresDialect) ResetTable(table string) []string {
+	return []string{
+		"TRUNCATE TABLE " + d.QuoteIdent(table) + " RESTART IDENTITY CASCADE",
+	}
+}
+
+// DialectOf returns the dialect of the driver behind db.
+func DialectOf(db *sql.DB) Dialect {
+	return dialectOfDriver(db.Driver())
+}
+
+func dialectOfDriver(d driver.Driver) Dialect {
+	switch d.(type) {
+	case *sqlite3.SQLiteDriver:
+		return SQLite
+	case *pq.Driver:
+		return Postgres
+	default:
+		return MySQL
+	}
+}
+
+// ResetTable deletes every row in table and restarts its ids.
+func ResetTable(ctx context.Context, db *sql.DB, table string) error {
+	for _, statement := range DialectOf(db).ResetTable(table) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert runs an INSERT written with ? placeholders and returns the id of the new row.
+func Insert(ctx context.Context, db *sql.DB, query string, args ...any) (int64, error) {
+	dialect := DialectOf(db)
+	query = dialect.Rebind(query)
+
+	if returning := dialect.ReturningID(); returning != "" {
+		var id int64
+		err := db.QueryRowContext(ctx, query+returning, args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const (
+	mysqlErrNoReferencedRow  = 1452
+	pqErrForeignKeyViolation = "23503"
+)
+
+// IsForeignKeyViolation reports whether err was caused by a row referring to a row which does not
+// exist.
+func IsForeignKeyViolation(err error) bool {
+	var (
+		mysqlErr  *mysql.MySQLError
+		pqErr     *pq.Error
+		sqliteErr sqlite3.Error
+	)
+	switch {
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == mysqlErrNoReferencedRow
+	case errors.As(err, &pqErr):
+		return pqErr.Code == pqErrForeignKeyViolation
+	case errors.As(err, &sqliteErr):
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+	}
+	return false
+}
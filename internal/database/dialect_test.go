@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/vivek-shah-13/store/internal/config"
+	"gotest.tools/v3/assert"
+)
+
+func TestSQLite_createOpenDrop(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn := config.Default().Database
+	conn.Driver = config.DriverSQLite
+	conn.Path = t.TempDir()
+	conn.Name = "store_google"
+
+	_, err := Open(ctx, conn)
+	assert.Assert(t, errors.Is(err, ErrDatabaseNotFound), err)
+
+	assert.NilError(t, Create(ctx, conn))
+	assert.ErrorContains(t, Create(ctx, conn), "failed to create sqlite database")
+
+	db, err := Open(ctx, conn)
+	assert.NilError(t, err)
+	defer db.Close()
+	assert.Equal(t, DialectOf(db), SQLite)
+	assert.Equal(t, DialectFor(conn.Driver), SQLite)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE Products(ID INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(255))")
+	assert.NilError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO Products (name) VALUES ('Bananas'), ('Milk')")
+	assert.NilError(t, err)
+
+	var name string
+	contains := DialectOf(db).Concat("'%'", "?", "'%'")
+	assert.NilError(t, db.QueryRowContext(ctx, "SELECT name FROM Products WHERE name LIKE "+contains, "ilk").Scan(&name))
+	assert.Equal(t, name, "Milk")
+	assert.NilError(t, db.QueryRowContext(ctx, "SELECT name FROM Products WHERE "+SQLite.Regexp("name"), "^B.*s$").Scan(&name))
+	assert.Equal(t, name, "Bananas")
+
+	assert.NilError(t, ResetTable(ctx, db, "Products"))
+	res, err := db.ExecContext(ctx, "INSERT INTO Products (name) VALUES ('Cookies')")
+	assert.NilError(t, err)
+	id, err := res.LastInsertId()
+	assert.NilError(t, err)
+	assert.Equal(t, id, int64(1))
+
+	assert.NilError(t, Drop(ctx, conn))
+	_, err = Open(ctx, conn)
+	assert.Assert(t, errors.Is(err, ErrDatabaseNotFound), err)
+}
+
+func TestDialect_Concat(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, MySQL.Concat("'%'", "?", "'%'"), "CONCAT('%', ?, '%')")
+	assert.Equal(t, SQLite.Concat("'%'", "?", "'%'"), "('%' || ? || '%')")
+}
+
+func TestDialect_Regexp(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, MySQL.Regexp("name"), "name REGEXP ?")
+	assert.Equal(t, Postgres.Regexp("name"), "name ~ ?")
+}
+
+func TestDialect_Like(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, MySQL.Like("name", '\\'), `name LIKE ? ESCAPE '\\'`)
+	assert.Equal(t, MySQL.Like("name", '!'), `name LIKE ? ESCAPE '!'`)
+	assert.Equal(t, Postgres.Like("name", '\\'), `name LIKE ? ESCAPE '\'`)
+	assert.Equal(t, SQLite.Like("name", '\''), `name LIKE ? ESCAPE ''''`)
+}
+
+func TestPostgres_Rebind(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		Postgres.Rebind("SELECT * FROM Customers WHERE email LIKE ('%' || ? || '%') AND state = ? AND note = 'why?'"),
+		"SELECT * FROM Customers WHERE email LIKE ('%' || $1 || '%') AND state = $2 AND note = 'why?'")
+	assert.Equal(t, MySQL.Rebind("SELECT ?"), "SELECT ?")
+	assert.Equal(t, SQLite.Rebind("SELECT ?"), "SELECT ?")
+}
+
+func TestDialect_QuoteIdent(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, MySQL.QuoteIdent("Customers"), "`Customers`")
+	assert.Equal(t, MySQL.QuoteIdent("a`b"), "`a``b`")
+	assert.Equal(t, SQLite.QuoteIdent("Customers"), `"Customers"`)
+	assert.Equal(t, Postgres.QuoteIdent("Customers"), `"customers"`)
+}
+
+func TestIsForeignKeyViolation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn := config.Default().Database
+	conn.Driver = config.DriverSQLite
+	conn.Path = t.TempDir()
+	conn.Name = "store_google"
+
+	assert.NilError(t, Create(ctx, conn))
+	db, err := Open(ctx, conn)
+	assert.NilError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE Customers(ID INTEGER PRIMARY KEY AUTOINCREMENT)")
+	assert.NilError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE Orders(ID INTEGER PRIMARY KEY AUTOINCREMENT, customer_id INT, FOREIGN KEY (customer_id) REFERENCES Customers(ID))")
+	assert.NilError(t, err)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO Orders (customer_id) VALUES (1)")
+	assert.Assert(t, IsForeignKeyViolation(err), err)
+
+	assert.Assert(t, IsForeignKeyViolation(&mysql.MySQLError{Number: 1452}))
+	assert.Assert(t, !IsForeignKeyViolation(&mysql.MySQLError{Number: 1049}))
+	assert.Assert(t, IsForeignKeyViolation(&pq.Error{Code: "23503"}))
+	assert.Assert(t, !IsForeignKeyViolation(errors.New("connection refused")))
+}
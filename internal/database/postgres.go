@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// postgresMaintenanceDB is connected to for statements which do not run against an org
+// database, since postgres always requires a database to connect to.
+const postgresMaintenanceDB = "postgres"
+
+const pgErrInvalidCatalogName = "3D000"
+
+// sslModes maps the TLS modes onto postgres sslmode values.
+var sslModes = map[string]string{
+	"":                           "disable",
+	config.TLSModeDisabled:       "disable",
+	config.TLSModePreferred:      "prefer",
+	config.TLSModeRequired:       "require",
+	config.TLSModeVerifyCA:       "verify-ca",
+	config.TLSModeVerifyIdentity: "verify-full",
+}
+
+func postgresDSN(conn config.Database) (string, error) {
+	sslMode, ok := sslModes[conn.TLS.Mode]
+	if !ok {
+		return "", errors.Errorf("unknown tls mode %q", conn.TLS.Mode)
+	}
+
+	name := conn.Name
+	if name == "" {
+		name = postgresMaintenanceDB
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	if conn.TLS.CAFile != "" {
+		query.Set("sslrootcert", conn.TLS.CAFile)
+	}
+	if conn.TLS.CertFile != "" {
+		query.Set("sslcert", conn.TLS.CertFile)
+	}
+	if conn.TLS.KeyFile != "" {
+		query.Set("sslkey", conn.TLS.KeyFile)
+	}
+	if timeout := time.Duration(conn.Pool.DialTimeout); timeout > 0 {
+		// connect_timeout is in whole seconds, round up so short timeouts are not disabled.
+		query.Set("connect_timeout", strconv.Itoa(int((timeout+time.Second-1)/time.Second)))
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(conn.User, conn.Password),
+		Host:     conn.Addr(),
+		Path:     "/" + name,
+		RawQuery: query.Encode(),
+	}
+	return u.String(), nil
+}
+
+func openPostgres(ctx context.Context, conn config.Database) (*sql.DB, error) {
+	dsn, err := postgresDSN(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(conn.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(conn.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(conn.Pool.ConnMaxLifetime))
+
+	err = retry(ctx, conn.Pool, func() error { return db.PingContext(ctx) })
+	if err != nil {
+		db.Close()
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgErrInvalidCatalogName {
+			return nil, fmt.Errorf("%w: %v", ErrDatabaseNotFound, err)
+		}
+		if requiresTLS(conn.TLS.Mode) && (isTLSError(err) || errors.Is(err, pq.ErrSSLNotSupported)) {
+			return nil, fmt.Errorf("%w: connecting to %s with tls mode %s: %v", ErrTLSHandshake, conn.Addr(), conn.TLS.Mode, err)
+		}
+		return nil, err
+	}
+
+	return db, nil
+}
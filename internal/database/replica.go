@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// ErrNoReplicaAvailable is returned by OpenReplica when no replica is reachable and within the
+// maximum lag.
+var ErrNoReplicaAvailable = errors.New("no read replica available")
+
+// OpenReplica connects to the first of replicas which is reachable and no more than maxLag
+// behind its primary.
+func OpenReplica(ctx context.Context, replicas []config.Database, maxLag time.Duration) (*sql.DB, error) {
+	return openReplica(ctx, replicas, maxLag, Open, ReplicationLag)
+}
+
+func openReplica(
+	ctx context.Context,
+	replicas []config.Database,
+	maxLag time.Duration,
+	open func(context.Context, config.Database) (*sql.DB, error),
+	lagOf func(context.Context, *sql.DB) (time.Duration, error),
+) (*sql.DB, error) {
+	for _, replica := range replicas {
+		db, err := open(ctx, replica)
+		if err != nil {
+			log.Printf("skipping read replica %s: %v", replica.Addr(), err)
+			continue
+		}
+
+		lag, err := lagOf(ctx, db)
+		if err != nil {
+			log.Printf("skipping read replica %s: failed to check replication lag: %v", replica.Addr(), err)
+			db.Close()
+			continue
+		}
+		if lag > maxLag {
+			log.Printf("skipping read replica %s: lag %s is over %s", replica.Addr(), lag, maxLag)
+			db.Close()
+			continue
+		}
+
+		return db, nil
+	}
+
+	return nil, ErrNoReplicaAvailable
+}
+
+// ReplicationLag returns how far the replica behind db is behind its primary.
+func ReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	switch DialectOf(db) {
+	case Postgres:
+		var seconds float64
+		err := db.QueryRowContext(ctx, `SELECT CASE WHEN pg_is_in_recovery()
+			THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+			ELSE 0 END`).Scan(&seconds)
+		return time.Duration(seconds * float64(time.Second)), err
+	case SQLite:
+		// sqlite databases are local files and never replicated.
+		return 0, nil
+	default:
+		return mysqlReplicationLag(ctx, db)
+	}
+}
+
+func mysqlReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		// servers before 8.0.22 only understand the old name.
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("server is not replicating")
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("replication is stopped")
+		}
+		seconds, err := strconv.Atoi(string(values[i]))
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid %s", column)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return 0, errors.New("replica status has no seconds behind source")
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+	"gotest.tools/v3/assert"
+)
+
+func TestOpenReplica(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	replicas := []config.Database{
+		{Host: "down", Port: 3306},
+		{Host: "lagging", Port: 3306},
+		{Host: "broken", Port: 3306},
+		{Host: "healthy", Port: 3306},
+	}
+
+	opened := map[*sql.DB]string{}
+	open := func(_ context.Context, conn config.Database) (*sql.DB, error) {
+		if conn.Host == "down" {
+			return nil, errors.New("connection refused")
+		}
+		db, err := sql.Open("sqlite3", ":memory:")
+		opened[db] = conn.Host
+		return db, err
+	}
+	lagOf := func(_ context.Context, db *sql.DB) (time.Duration, error) {
+		switch opened[db] {
+		case "lagging":
+			return time.Minute, nil
+		case "broken":
+			return 0, errors.New("replication is stopped")
+		default:
+			return time.Second, nil
+		}
+	}
+
+	db, err := openReplica(ctx, replicas, 5*time.Second, open, lagOf)
+	assert.NilError(t, err)
+	defer db.Close()
+	assert.Equal(t, opened[db], "healthy")
+
+	_, err = openReplica(ctx, replicas[:3], 5*time.Second, open, lagOf)
+	assert.Assert(t, errors.Is(err, ErrNoReplicaAvailable))
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// NewTLSConfig builds the tls.Config for the required and verify-* modes. serverName is the
+// host name checked in verify-identity mode.
+func NewTLSConfig(t config.TLS, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, errors.New("tls client certificate requires both CertFile and KeyFile")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load tls client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read tls CA bundle %s", t.CAFile)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("tls CA bundle %s contains no certificates", t.CAFile)
+		}
+	}
+
+	switch t.Mode {
+	case config.TLSModeRequired:
+		cfg.InsecureSkipVerify = true
+	case config.TLSModeVerifyCA:
+		// the standard verification always checks the host name, so skip it and verify the
+		// chain ourselves.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = verifyChain(cfg.RootCAs)
+	case config.TLSModeVerifyIdentity:
+	default:
+		return nil, errors.Errorf("tls mode %q does not use a custom tls config", t.Mode)
+	}
+
+	return cfg, nil
+}
+
+// verifyChain returns a function which verifies the server certificate chain against roots, or
+// the system roots if roots is nil, without checking the host name.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server presented no tls certificate")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse server certificate")
+			}
+			certs[i] = cert
+		}
+
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
+
+// tlsParam returns the value of the driver's tls DSN parameter for conn, registering a custom
+// tls config with the driver where needed.
+func tlsParam(conn config.Database, register func(string, *tls.Config) error) (string, error) {
+	switch conn.TLS.Mode {
+	case "", config.TLSModeDisabled:
+		return "false", nil
+	case config.TLSModePreferred:
+		return "preferred", nil
+	case config.TLSModeRequired, config.TLSModeVerifyCA, config.TLSModeVerifyIdentity:
+		cfg, err := NewTLSConfig(conn.TLS, conn.Host)
+		if err != nil {
+			return "", err
+		}
+		key := fmt.Sprintf("store-%s-%s", conn.Addr(), conn.Name)
+		if err := register(key, cfg); err != nil {
+			return "", errors.Wrapf(err, "failed to register tls config")
+		}
+		return key, nil
+	default:
+		return "", errors.Errorf("unknown tls mode %q, must be one of %s, %s, %s, %s or %s", conn.TLS.Mode,
+			config.TLSModeDisabled, config.TLSModePreferred, config.TLSModeRequired, config.TLSModeVerifyCA, config.TLSModeVerifyIdentity)
+	}
+}
+
+// requiresTLS reports whether the connection must fail when TLS cannot be established.
+func requiresTLS(mode string) bool {
+	return mode == config.TLSModeRequired || mode == config.TLSModeVerifyCA || mode == config.TLSModeVerifyIdentity
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/vivek-shah-13/store/internal/config"
+	"gotest.tools/v3/assert"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	assert.NilError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	assert.NilError(t, err)
+
+	certFile = dir + "/" + c.cert.Subject.CommonName + ".crt"
+	keyFile = dir + "/" + c.cert.Subject.CommonName + ".key"
+	assert.NilError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600))
+	assert.NilError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig_verifyCA_verifiesChainButNotHostName(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	caFile, _ := ca.write(t, dir)
+	clientFile, clientKey := newTestCert(t, "client", ca).write(t, dir)
+
+	cfg, err := NewTLSConfig(config.TLS{
+		Mode:     config.TLSModeVerifyCA,
+		CAFile:   caFile,
+		CertFile: clientFile,
+		KeyFile:  clientKey,
+	}, "db.internal")
+	assert.NilError(t, err)
+	assert.Equal(t, len(cfg.Certificates), 1)
+
+	server := newTestCert(t, "some-other-host", ca)
+	assert.NilError(t, cfg.VerifyPeerCertificate([][]byte{server.der}, nil))
+
+	untrusted := newTestCert(t, "db.internal", newTestCert(t, "other-ca", nil))
+	assert.ErrorContains(t, cfg.VerifyPeerCertificate([][]byte{untrusted.der}, nil), "unknown authority")
+}
+
+func TestNewTLSConfig_verifyIdentity_usesStandardVerification(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	caFile, _ := newTestCert(t, "ca", nil).write(t, dir)
+
+	cfg, err := NewTLSConfig(config.TLS{Mode: config.TLSModeVerifyIdentity, CAFile: caFile}, "db.internal")
+	assert.NilError(t, err)
+	assert.Equal(t, cfg.InsecureSkipVerify, false)
+	assert.Equal(t, cfg.ServerName, "db.internal")
+	assert.Assert(t, cfg.RootCAs != nil)
+}
+
+func TestNewTLSConfig_invalidSettings_returnsAnError(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	empty := dir + "/empty.pem"
+	assert.NilError(t, ioutil.WriteFile(empty, nil, 0600))
+
+	_, err := NewTLSConfig(config.TLS{Mode: config.TLSModeRequired, CertFile: "client.crt"}, "")
+	assert.ErrorContains(t, err, "requires both CertFile and KeyFile")
+
+	_, err = NewTLSConfig(config.TLS{Mode: config.TLSModeVerifyCA, CAFile: empty}, "")
+	assert.ErrorContains(t, err, "contains no certificates")
+
+	_, err = NewTLSConfig(config.TLS{Mode: config.TLSModeVerifyCA, CAFile: dir + "/missing.pem"}, "")
+	assert.ErrorContains(t, err, "failed to read tls CA bundle")
+}
+
+func TestTLSParam(t *testing.T) {
+	t.Parallel()
+
+	registered := map[string]*tls.Config{}
+	register := func(key string, cfg *tls.Config) error {
+		registered[key] = cfg
+		return nil
+	}
+
+	conn := config.Database{Host: "db.internal", Port: 3306, Name: "store_google"}
+
+	for mode, want := range map[string]string{
+		"":                           "false",
+		config.TLSModeDisabled:       "false",
+		config.TLSModePreferred:      "preferred",
+		config.TLSModeRequired:       "store-db.internal:3306-store_google",
+		config.TLSModeVerifyIdentity: "store-db.internal:3306-store_google",
+	} {
+		conn.TLS.Mode = mode
+		got, err := tlsParam(conn, register)
+		assert.NilError(t, err, mode)
+		assert.Equal(t, got, want, mode)
+	}
+	assert.Equal(t, len(registered), 1)
+
+	conn.TLS.Mode = "sometimes"
+	_, err := tlsParam(conn, register)
+	assert.ErrorContains(t, err, `unknown tls mode "sometimes"`)
+}
@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors returned when a record does not exist.
+var (
+	ErrCustomerNotFound = errors.New("Customer ID does not exist")
+	ErrProductNotFound  = errors.New("Product ID does not exist")
+	ErrOrderNotFound    = errors.New("Order ID does not exist")
+)
+
+// Customer is a row of the Customers table.
+type Customer struct {
+	ID    int    `json:"id" yaml:"id" db:"ID"`
+	Email string `json:"email" yaml:"email" db:"email"`
+	State string `json:"state" yaml:"state" db:"state"`
+}
+
+// Product is a row of the Products table. Sku is nil for products without one.
+type Product struct {
+	ID    int     `json:"id" yaml:"id" db:"ID"`
+	Name  string  `json:"name" yaml:"name" db:"name"`
+	Price float64 `json:"price" yaml:"price" db:"price"`
+	Sku   *string `json:"sku" yaml:"sku" db:"sku"`
+}
+
+// Order is a row of the Orders table. CreatedAt is nil for orders created without a timestamp.
+type Order struct {
+	ID         int        `json:"id" yaml:"id" db:"ID"`
+	CreatedAt  *time.Time `json:"created_at" yaml:"created_at" db:"created_at"`
+	CustomerID int        `json:"customer_id" yaml:"customer_id" db:"customer_id"`
+	ProductID  int        `json:"product_id" yaml:"product_id" db:"product_id"`
+}
+
+// OrderDetails is an order together with its customer and product.
+type OrderDetails struct {
+	Order    *Order    `json:"order" yaml:"order"`
+	Customer *Customer `json:"customer" yaml:"customer"`
+	Product  *Product  `json:"product" yaml:"product"`
+}
+
+// ValidationError reports a field which does not hold a valid value.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Invalid returns a *ValidationError for field.
+func Invalid(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
+
+// States holds the two letter codes of the U.S. states and territories.
+var States = map[string]bool{
+	"AL": true,
+	"AK": true,
+	"AZ": true,
+	"AR": true,
+	"AS": true,
+	"CA": true,
+	"CO": true,
+	"CT": true,
+	"DE": true,
+	"DC": true,
+	"FL": true,
+	"GA": true,
+	"GU": true,
+	"HI": true,
+	"ID": true,
+	"IL": true,
+	"IN": true,
+	"IA": true,
+	"KS": true,
+	"KY": true,
+	"LA": true,
+	"ME": true,
+	"MD": true,
+	"MA": true,
+	"MI": true,
+	"MN": true,
+	"MS": true,
+	"MO": true,
+	"MT": true,
+	"NE": true,
+	"NV": true,
+	"NH": true,
+	"NJ": true,
+	"NM": true,
+	"NY": true,
+	"NC": true,
+	"ND": true,
+	"MP": true,
+	"OH": true,
+	"OK": true,
+	"OR": true,
+	"PA": true,
+	"PR": true,
+	"RI": true,
+	"SC": true,
+	"SD": true,
+	"TN": true,
+	"TX": true,
+	"VT": true,
+	"UT": true,
+	"VA": true,
+	"VI": true,
+	"WA": true,
+	"WV": true,
+	"WI": true,
+	"WY": true,
+}
+
+// Validate checks that the customer has an email and a valid state code.
+func (c *Customer) Validate() error {
+	if c.Email == "" {
+		return Invalid("email", "Email must not be empty")
+	}
+	if len(c.State) != 2 {
+		return Invalid("state", "State length must be 2")
+	}
+	if !States[strings.ToUpper(c.State)] {
+		return Invalid("state", "State must be a valid U.S. State or Territory")
+	}
+	return nil
+}
+
+// Validate checks that the product has a name and a price which is not negative.
+func (p *Product) Validate() error {
+	if p.Name == "" {
+		return Invalid("name", "Name must not be empty")
+	}
+	if p.Price < 0 {
+		return Invalid("price", "Price must not be negative")
+	}
+	if p.Sku != nil && *p.Sku == "" {
+		return Invalid("sku", "Sku must not be empty, leave it unset instead")
+	}
+	return nil
+}
+
+// Validate checks that the order refers to a customer and a product.
+func (o *Order) Validate() error {
+	if o.CustomerID <= 0 {
+		return Invalid("customer_id", "Customer ID must be positive")
+	}
+	if o.ProductID <= 0 {
+		return Invalid("product_id", "Product ID must be positive")
+	}
+	return nil
+}
+
+// SkuString returns the sku, or "" if the product has none.
+func (p *Product) SkuString() string {
+	if p.Sku == nil {
+		return ""
+	}
+	return *p.Sku
+}
+
+// OptionalString returns a pointer to s, or nil if s is empty, for nullable fields such as
+// Product.Sku.
+func OptionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
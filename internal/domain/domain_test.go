@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCustomer_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, (&Customer{Email: "vivek.s@outreach.io", State: "wa"}).Validate())
+	assert.ErrorContains(t, (&Customer{State: "WA"}).Validate(), "Email must not be empty")
+	assert.ErrorContains(t, (&Customer{Email: "vivek.s@outreach.io", State: "PPP"}).Validate(), "State length must be 2")
+	assert.ErrorContains(t, (&Customer{Email: "vivek.s@outreach.io", State: "PP"}).Validate(), "State must be a valid U.S. State or Territory")
+}
+
+func TestProduct_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, (&Product{Name: "laptop", Price: 25.5}).Validate())
+	assert.ErrorContains(t, (&Product{Price: 25.5}).Validate(), "Name must not be empty")
+	assert.ErrorContains(t, (&Product{Name: "laptop", Price: -1}).Validate(), "Price must not be negative")
+	empty := ""
+	assert.ErrorContains(t, (&Product{Name: "laptop", Sku: &empty}).Validate(), "Sku must not be empty")
+}
+
+func TestOrder_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, (&Order{CustomerID: 1, ProductID: 2}).Validate())
+	assert.ErrorContains(t, (&Order{ProductID: 2}).Validate(), "Customer ID must be positive")
+	assert.ErrorContains(t, (&Order{CustomerID: 1}).Validate(), "Product ID must be positive")
+}
+
+func TestJSON_nullableFields(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(&Product{ID: 1, Name: "laptop", Price: 25.5})
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"id":1,"name":"laptop","price":25.5,"sku":null}`)
+
+	data, err = json.Marshal(&Product{ID: 1, Name: "laptop", Price: 25.5, Sku: OptionalString("abcde")})
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"id":1,"name":"laptop","price":25.5,"sku":"abcde"}`)
+
+	createdAt := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, err = json.Marshal(&Order{ID: 1, CreatedAt: &createdAt, CustomerID: 2, ProductID: 3})
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"id":1,"created_at":"2023-06-01T12:00:00Z","customer_id":2,"product_id":3}`)
+
+	var o Order
+	assert.NilError(t, json.Unmarshal([]byte(`{"id":1,"created_at":null,"customer_id":2,"product_id":3}`), &o))
+	assert.DeepEqual(t, o, Order{ID: 1, CustomerID: 2, ProductID: 3})
+}
@@ -0,0 +1,162 @@
+package expr
+
+import (
+	"strings"
+	"time"
+)
+
+// SQL compiles e to a condition with ? placeholders for its values, which are returned in order.
+// column returns the SQL expression of a field.
+func SQL(e Expr, column func(field string) string) (string, []any) {
+	switch e := e.(type) {
+	case *Logical:
+		left, args := SQL(e.Left, column)
+		right, rightArgs := SQL(e.Right, column)
+		op := " AND "
+		if e.Or {
+			op = " OR "
+		}
+		return "(" + left + op + right + ")", append(args, rightArgs...)
+	case *Not:
+		x, args := SQL(e.X, column)
+		return "NOT " + x, args
+	case *Compare:
+		op := e.Op
+		if op == "!=" {
+			op = "<>"
+		}
+		return "(" + column(e.Field) + " " + op + " ?)", []any{e.Value}
+	case *In:
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(e.Values)), ", ")
+		op := " IN ("
+		if e.Not {
+			op = " NOT IN ("
+		}
+		return "(" + column(e.Field) + op + placeholders + "))", e.Values
+	case *IsNull:
+		if e.Not {
+			return "(" + column(e.Field) + " IS NOT NULL)", nil
+		}
+		return "(" + column(e.Field) + " IS NULL)", nil
+	}
+	panic("unknown expression")
+}
+
+// truth is the result of a condition under SQL's three valued logic, where comparisons with
+// NULL are unknown.
+type truth int
+
+const (
+	unknown truth = iota
+	no
+	yes
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return yes
+	}
+	return no
+}
+
+// Eval reports whether e holds for a record, the way SQL would. value returns the value of a
+// field, with the Go type of its field, or nil for NULL.
+func Eval(e Expr, value func(field string) any) bool {
+	return eval(e, value) == yes
+}
+
+func eval(e Expr, value func(string) any) truth {
+	switch e := e.(type) {
+	case *Logical:
+		left, right := eval(e.Left, value), eval(e.Right, value)
+		switch {
+		case e.Or && (left == yes || right == yes):
+			return yes
+		case !e.Or && (left == no || right == no):
+			return no
+		case left == unknown || right == unknown:
+			return unknown
+		}
+		return truthOf(!e.Or)
+	case *Not:
+		return negate(eval(e.X, value))
+	case *Compare:
+		c, ok := compare(value(e.Field), e.Value)
+		if !ok {
+			return unknown
+		}
+		switch e.Op {
+		case "=":
+			return truthOf(c == 0)
+		case "!=":
+			return truthOf(c != 0)
+		case "<":
+			return truthOf(c < 0)
+		case "<=":
+			return truthOf(c <= 0)
+		case ">":
+			return truthOf(c > 0)
+		}
+		return truthOf(c >= 0)
+	case *In:
+		result := no
+		for _, v := range e.Values {
+			c, ok := compare(value(e.Field), v)
+			if !ok {
+				result = unknown
+				break
+			}
+			if c == 0 {
+				result = yes
+				break
+			}
+		}
+		if e.Not {
+			return negate(result)
+		}
+		return result
+	case *IsNull:
+		return truthOf((value(e.Field) == nil) != e.Not)
+	}
+	panic("unknown expression")
+}
+
+func negate(t truth) truth {
+	switch t {
+	case yes:
+		return no
+	case no:
+		return yes
+	}
+	return unknown
+}
+
+// compare compares a and b, which have the same type, returning false if either is nil.
+func compare(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	switch a := a.(type) {
+	case int:
+		return a - b.(int), true
+	case float64:
+		switch b := b.(float64); {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		b := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1, true
+		case a.After(b):
+			return 1, true
+		}
+		return 0, true
+	}
+	return strings.Compare(a.(string), b.(string)), true
+}
@@ -0,0 +1,128 @@
+// Package expr implements the filter expressions of the --where flag, such as
+//
+//	product.price > 10 and customer.state in (WA, CA)
+//
+// Expressions are parsed and type checked against the fields of a Schema, then either compiled
+// to a parameterised SQL condition or evaluated against a record.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Type is the type of a field.
+type Type int
+
+const (
+	Int Type = iota
+	Float
+	String
+	Time
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "an integer"
+	case Float:
+		return "a number"
+	case Time:
+		return "a date or time"
+	default:
+		return "a string"
+	}
+}
+
+// Schema maps the names of the fields an expression can use to their types.
+type Schema map[string]Type
+
+// Names returns the sorted names of the fields of s, or those of type types if any are given.
+func (s Schema) Names(types ...Type) []string {
+	names := make([]string, 0, len(s))
+	for name, t := range s {
+		if len(types) == 0 || oneOf(t, types) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func oneOf(t Type, types []Type) bool {
+	for _, typ := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Schema) names() string {
+	return strings.Join(s.Names(), ", ")
+}
+
+// Expr is a parsed expression. Values in it have the Go type of their field: int, float64,
+// string or time.Time.
+type Expr interface {
+	expr()
+}
+
+// Logical is "Left and Right" or "Left or Right".
+type Logical struct {
+	Or          bool
+	Left, Right Expr
+}
+
+// Not negates X.
+type Not struct {
+	X Expr
+}
+
+// Compare is "Field Op Value", where Op is one of =, !=, <, <=, > and >=.
+type Compare struct {
+	Field string
+	Op    string
+	Value any
+}
+
+// In is "Field in (Values...)", or "Field not in (Values...)" when Not is set.
+type In struct {
+	Field  string
+	Values []any
+	Not    bool
+}
+
+// IsNull is "Field is null", or "Field is not null" when Not is set.
+type IsNull struct {
+	Field string
+	Not   bool
+}
+
+func (*Logical) expr() {}
+func (*Not) expr()     {}
+func (*Compare) expr() {}
+func (*In) expr()      {}
+func (*IsNull) expr()  {}
+
+// Error is a syntax or type error in an expression.
+type Error struct {
+	// Column is the position of the error, counting runes from 1.
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// parseTime parses the value of a Time field, a date such as 2023-06-01 or an RFC 3339 time.
+func parseTime(s string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), err == nil
+}
@@ -0,0 +1,92 @@
+package expr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+)
+
+var schema = Schema{
+	"id":             Int,
+	"created_at":     Time,
+	"customer.state": String,
+	"product.price":  Float,
+	"product.sku":    String,
+}
+
+func TestParse_compilesToSQL(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]string{
+		"product.price > 10 and customer.state in (WA, 'CA')":     "((product.price > ?) AND (customer.state IN (?, ?)))",
+		"id = 1 or id == 2 and not (product.sku is null)":         "((id = ?) OR ((id = ?) AND NOT (product.sku IS NULL)))",
+		"ID <> 3 AND created_at >= 2023-06-01":                    "((id <> ?) AND (created_at >= ?))",
+		`customer.state not in ("WA") or product.sku is not null`: "((customer.state NOT IN (?)) OR (product.sku IS NOT NULL))",
+	} {
+		e, err := Parse(input, schema)
+		assert.NilError(t, err, input)
+		sql, _ := SQL(e, func(field string) string { return field })
+		assert.Equal(t, sql, want, input)
+	}
+
+	e, err := Parse("product.price <= 10 and created_at < '2023-06-01T12:00:00+02:00'", schema)
+	assert.NilError(t, err)
+	_, args := SQL(e, func(field string) string { return field })
+	assert.DeepEqual(t, args, []any{10.0, time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)})
+}
+
+func TestParse_errorsHavePositions(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]string{
+		"product.colour = red":          `column 1: unknown field "product.colour", expected one of created_at, customer.state, id, product.price, product.sku`,
+		"id = abc":                      `column 6: expected an integer for id, got "abc"`,
+		"id = 1 and":                    "column 11: unexpected end of expression, expected a field such as",
+		"(id = 1":                       "column 8: unexpected end of expression, expected )",
+		"id 1":                          `column 4: unexpected "1" after id, expected =, !=, <, <=, >, >=, in or is`,
+		"customer.state in (WA CA)":     `column 23: unexpected "CA", expected , or )`,
+		"product.sku = null":            "column 15: cannot compare with null, use product.sku is null",
+		"customer.state = 'WA":          "column 18: unterminated string",
+		"created_at > yesterday":        `column 14: expected a date or time for created_at, got "yesterday"`,
+		"id = 1 id = 2":                 `column 8: unexpected "id", expected and, or or the end of the expression`,
+		"id ! 1":                        `column 4: unexpected "!", did you mean "!="?`,
+		"product.price > 1 and id is 2": `column 29: unexpected "2", expected null`,
+	} {
+		_, err := Parse(input, schema)
+		assert.ErrorContains(t, err, want, input)
+		var exprErr *Error
+		assert.Assert(t, errors.As(err, &exprErr), input)
+	}
+}
+
+func TestEval_followsSQLNullLogic(t *testing.T) {
+	t.Parallel()
+
+	record := map[string]any{
+		"id":             1,
+		"created_at":     nil,
+		"customer.state": "WA",
+		"product.price":  12.5,
+		"product.sku":    nil,
+	}
+	value := func(field string) any { return record[field] }
+
+	for input, want := range map[string]bool{
+		"product.price > 10 and customer.state in (WA, CA)": true,
+		"product.price > 12.5":                              false,
+		"customer.state not in (CA)":                        true,
+		"product.sku = abc":                                 false,
+		"not product.sku = abc":                             false,
+		"product.sku != abc":                                false,
+		"product.sku is null":                               true,
+		"not (product.sku = abc) or id = 1":                 true,
+		"product.sku not in (abc)":                          false,
+		"created_at < 2023-06-01":                           false,
+	} {
+		e, err := Parse(input, schema)
+		assert.NilError(t, err, input)
+		assert.Equal(t, Eval(e, value), want, input)
+	}
+}
@@ -0,0 +1,298 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenWord is a field name, keyword or unquoted value such as WA, 10 or 2023-06-01.
+	tokenWord
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	column int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// isWordRune reports whether r can be part of a word, which is anything up to white space,
+// quotes, parentheses, commas and operators.
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`'"(),=!<>`, r)
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r, column := runes[i], i+1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", column})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", column})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", column})
+			i++
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			for i++; ; i++ {
+				if i == len(runes) {
+					return nil, &Error{column, "unterminated string"}
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				} else if runes[i] == r {
+					break
+				}
+				b.WriteRune(runes[i])
+			}
+			tokens = append(tokens, token{tokenString, b.String(), column})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && strings.ContainsRune("=>", runes[i+1]) {
+				op += string(runes[i+1])
+			}
+			switch op {
+			case "=", "==", "!=", "<>", "<", "<=", ">", ">=":
+			default:
+				op = string(r)
+				if op == "!" {
+					return nil, &Error{column, `unexpected "!", did you mean "!="?`}
+				}
+			}
+			tokens = append(tokens, token{tokenOp, op, column})
+			i += len(op)
+		default:
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[start:i]), column})
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(runes) + 1}), nil
+}
+
+var keywords = map[string]bool{"and": true, "or": true, "not": true, "in": true, "is": true, "null": true}
+
+// Parse parses input and checks its fields and values against schema. Errors are *Error.
+func Parse(input string, schema Schema) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, schema: schema}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s, expected and, or or the end of the expression", t.describe())
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	schema Schema
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the keyword kw.
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokenWord && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &Error{t.column, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("or") {
+		var right Expr
+		right, err = p.and()
+		left = &Logical{Or: true, Left: left, Right: right}
+	}
+	return left, err
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.not()
+	for err == nil && p.keyword("and") {
+		var right Expr
+		right, err = p.not()
+		left = &Logical{Left: left, Right: right}
+	}
+	return left, err
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.keyword("not") {
+		x, err := p.not()
+		return &Not{X: x}, err
+	}
+	if p.peek().kind == tokenLParen {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, p.errorf(t, "unexpected %s, expected )", t.describe())
+		}
+		return e, nil
+	}
+	return p.condition()
+}
+
+func (p *parser) condition() (Expr, error) {
+	t := p.next()
+	if t.kind != tokenWord || keywords[strings.ToLower(t.text)] {
+		return nil, p.errorf(t, "unexpected %s, expected a field such as %s", t.describe(), p.schema.names())
+	}
+	field := strings.ToLower(t.text)
+	typ, ok := p.schema[field]
+	if !ok {
+		return nil, p.errorf(t, "unknown field %q, expected one of %s", t.text, p.schema.names())
+	}
+
+	switch op := p.peek(); {
+	case op.kind == tokenOp:
+		p.next()
+		v, err := p.value(field, typ)
+		if err != nil {
+			return nil, err
+		}
+		return &Compare{Field: field, Op: normalizeOp(op.text), Value: v}, nil
+	case p.keyword("is"):
+		not := p.keyword("not")
+		if !p.keyword("null") {
+			t := p.peek()
+			return nil, p.errorf(t, "unexpected %s, expected null", t.describe())
+		}
+		return &IsNull{Field: field, Not: not}, nil
+	case p.keyword("in"):
+		values, err := p.list(field, typ)
+		return &In{Field: field, Values: values}, err
+	case p.keyword("not"):
+		if !p.keyword("in") {
+			t := p.peek()
+			return nil, p.errorf(t, "unexpected %s, expected in", t.describe())
+		}
+		values, err := p.list(field, typ)
+		return &In{Field: field, Values: values, Not: true}, err
+	default:
+		return nil, p.errorf(op, "unexpected %s after %s, expected =, !=, <, <=, >, >=, in or is", op.describe(), field)
+	}
+}
+
+func normalizeOp(op string) string {
+	switch op {
+	case "==":
+		return "="
+	case "<>":
+		return "!="
+	}
+	return op
+}
+
+// list parses the parenthesised values of an in condition.
+func (p *parser) list(field string, typ Type) ([]any, error) {
+	if t := p.next(); t.kind != tokenLParen {
+		return nil, p.errorf(t, "unexpected %s, expected ( after in", t.describe())
+	}
+
+	var values []any
+	for {
+		v, err := p.value(field, typ)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+
+		switch t := p.next(); t.kind {
+		case tokenComma:
+		case tokenRParen:
+			return values, nil
+		default:
+			return nil, p.errorf(t, "unexpected %s, expected , or )", t.describe())
+		}
+	}
+}
+
+// value parses a value of field, converting it to the Go type of typ.
+func (p *parser) value(field string, typ Type) (any, error) {
+	t := p.next()
+	if t.kind == tokenWord && strings.EqualFold(t.text, "null") {
+		return nil, p.errorf(t, "cannot compare with null, use %s is null or %s is not null", field, field)
+	}
+	if t.kind != tokenWord && t.kind != tokenString {
+		return nil, p.errorf(t, "unexpected %s, expected %s for %s", t.describe(), typ, field)
+	}
+
+	switch typ {
+	case Int:
+		if v, err := strconv.Atoi(t.text); err == nil {
+			return v, nil
+		}
+	case Float:
+		if v, err := strconv.ParseFloat(t.text, 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return v, nil
+		}
+	case Time:
+		if v, ok := parseTime(t.text); ok {
+			return v, nil
+		}
+	default:
+		return t.text, nil
+	}
+	return nil, p.errorf(t, "expected %s for %s, got %s", typ, field, t.describe())
+}
@@ -5,26 +5,91 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 const DefaultMigrationStatePath = "migration_state.json"
 
+// CurrentVersion is the version of the migration state format written by SaveMigrationState.
+// Version 1 is the original format, which only tracked LastRanMigrationID per org and had no
+// Version field at all.
+const CurrentVersion = 2
+
 type MigrationState struct {
-	Orgs []*OrgMigrationState
+	Version int
+	Orgs    []*OrgMigrationState
 }
 
 type OrgMigrationState struct {
 	Name               string
 	LastRanMigrationID int
+	History            []*AppliedMigration `json:",omitempty"`
+	UpdatedAt          time.Time
+	LastError          string `json:",omitempty"`
+}
+
+// AppliedMigration records a single migration which was successfully applied to an org.
+type AppliedMigration struct {
+	ID        int
+	File      string
+	AppliedAt time.Time
+}
+
+// RecordApplied marks the migration with the given id as applied and clears any previous error.
+func (o *OrgMigrationState) RecordApplied(id int, file string, at time.Time) {
+	o.LastRanMigrationID = id
+	o.History = append(o.History, &AppliedMigration{ID: id, File: file, AppliedAt: at})
+	o.UpdatedAt = at
+	o.LastError = ""
+}
+
+// RecordFailure stores err as the last error seen while migrating the org.
+func (o *OrgMigrationState) RecordFailure(err error, at time.Time) {
+	o.UpdatedAt = at
+	o.LastError = err.Error()
+}
+
+// upgrades maps a state version to the function which upgrades it to the next version.
+var upgrades = map[int]func(*MigrationState) error{
+	1: upgradeV1,
+}
+
+// upgradeV1 upgrades the original format. The applied history of those orgs is unknown, so it
+// is left empty.
+func upgradeV1(state *MigrationState) error {
+	state.Version = 2
+	return nil
+}
+
+func upgrade(state *MigrationState) error {
+	if state.Version == 0 {
+		state.Version = 1
+	}
+	if state.Version > CurrentVersion {
+		return errors.Errorf("migration state version %d is newer than supported version %d", state.Version, CurrentVersion)
+	}
+
+	for state.Version < CurrentVersion {
+		fn, ok := upgrades[state.Version]
+		if !ok {
+			return errors.Errorf("no upgrade available for migration state version %d", state.Version)
+		}
+		if err := fn(state); err != nil {
+			return errors.Wrapf(err, "failed to upgrade migration state from version %d", state.Version)
+		}
+	}
+
+	return nil
 }
 
 func LoadMigrationState(ctx context.Context, path string) (*MigrationState, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return &MigrationState{}, nil
+			return &MigrationState{Version: CurrentVersion}, nil
 		}
 
 		return nil, errors.Wrapf(err, "failed to read %s", path)
@@ -35,14 +100,48 @@ func LoadMigrationState(ctx context.Context, path string) (*MigrationState, erro
 		return nil, errors.Wrapf(err, "failed to unmarshal migration state data")
 	}
 
+	if err := upgrade(&state); err != nil {
+		return nil, err
+	}
+
 	return &state, nil
 }
 
+// SaveMigrationState stamps state with CurrentVersion and writes it to path. The data is written
+// to a temporary file in the same directory and renamed over path, so a crash mid-write never
+// leaves a truncated file behind.
 func SaveMigrationState(ctx context.Context, state *MigrationState, path string) error {
+	state.Version = CurrentVersion
+
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return errors.Wrapf(err, "failed to marshal migration state")
 	}
 
-	return ioutil.WriteFile(path, data, 0644)
+	return writeFileAtomic(path, data, 0644)
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary file for %s", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to write %s", tmp.Name())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to sync %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", tmp.Name())
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return errors.Wrapf(err, "failed to chmod %s", tmp.Name())
+	}
+
+	return errors.Wrapf(os.Rename(tmp.Name(), path), "failed to rename %s to %s", tmp.Name(), path)
 }
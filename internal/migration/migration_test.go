@@ -2,7 +2,10 @@ package migration
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -14,6 +17,7 @@ func TestLoadSaveMigrationState(t *testing.T) {
 	path := t.TempDir() + "/migration_state.json"
 
 	in := MigrationState{
+		Version: CurrentVersion,
 		Orgs: []*OrgMigrationState{
 			{
 				Name:               "google",
@@ -49,6 +53,7 @@ func TestSaveMigrationState_willOverwriteExistingData(t *testing.T) {
 	path := t.TempDir() + "/migration_state.json"
 
 	in1 := MigrationState{
+		Version: CurrentVersion,
 		Orgs: []*OrgMigrationState{
 			{
 				Name:               "google",
@@ -62,6 +67,7 @@ func TestSaveMigrationState_willOverwriteExistingData(t *testing.T) {
 	}
 
 	in2 := MigrationState{
+		Version: CurrentVersion,
 		Orgs: []*OrgMigrationState{
 			{
 				Name:               "google",
@@ -93,5 +99,99 @@ func TestLoadMigrationState_fileDoesNotExist_returnsEmptyState(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.DeepEqual(t, *out, MigrationState{})
+	assert.DeepEqual(t, *out, MigrationState{Version: CurrentVersion})
+}
+
+func TestLoadMigrationState_versionOneFormat_isUpgraded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := t.TempDir() + "/migration_state.json"
+
+	data := `{"Orgs": [{"Name": "google", "LastRanMigrationID": 1}]}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := LoadMigrationState(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.DeepEqual(t, *out, MigrationState{
+		Version: CurrentVersion,
+		Orgs: []*OrgMigrationState{
+			{
+				Name:               "google",
+				LastRanMigrationID: 1,
+			},
+		},
+	})
+}
+
+func TestLoadMigrationState_newerVersion_returnsAnError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := t.TempDir() + "/migration_state.json"
+
+	if err := ioutil.WriteFile(path, []byte(`{"Version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadMigrationState(ctx, path)
+	assert.ErrorContains(t, err, "newer than supported")
+}
+
+func TestSaveMigrationState_leavesNoTemporaryFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if err := SaveMigrationState(ctx, &MigrationState{}, dir+"/migration_state.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(files), 1)
+	assert.Equal(t, files[0].Name(), "migration_state.json")
+}
+
+func TestOrgMigrationState_recordsHistoryAndErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := t.TempDir() + "/migration_state.json"
+	at := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	org := &OrgMigrationState{Name: "google", LastRanMigrationID: -1}
+	org.RecordFailure(errors.New("boom"), at)
+	assert.Equal(t, org.LastError, "boom")
+
+	org.RecordApplied(0, "initial_0000.sql", at)
+	org.RecordApplied(1, "addProductSku_0001.sql", at)
+
+	if err := SaveMigrationState(ctx, &MigrationState{Orgs: []*OrgMigrationState{org}}, path); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := LoadMigrationState(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.DeepEqual(t, *out.Orgs[0], OrgMigrationState{
+		Name:               "google",
+		LastRanMigrationID: 1,
+		History: []*AppliedMigration{
+			{ID: 0, File: "initial_0000.sql", AppliedAt: at},
+			{ID: 1, File: "addProductSku_0001.sql", AppliedAt: at},
+		},
+		UpdatedAt: at,
+	})
 }
@@ -0,0 +1,64 @@
+package org
+
+import (
+	"fmt"
+
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// Catalog maps each org to the connection settings of its database.
+type Catalog struct {
+	defaults  config.Database
+	orgs      map[string]*config.OrgConnection
+	lookupEnv func(string) (string, bool)
+}
+
+// NewCatalog returns a catalog for the orgs in cfg. Orgs which are not listed use the default
+// database settings.
+func NewCatalog(cfg *config.Config, lookupEnv func(string) (string, bool)) *Catalog {
+	return &Catalog{
+		defaults:  cfg.Database,
+		orgs:      cfg.Orgs,
+		lookupEnv: lookupEnv,
+	}
+}
+
+// Connection returns the resolved connection settings for the org, including its password.
+func (c *Catalog) Connection(name string) (config.Database, error) {
+	if err := ValidateName(name); err != nil {
+		return config.Database{}, err
+	}
+
+	db := c.defaults
+	db.Name = DatabaseName(name)
+
+	o, ok := c.orgs[name]
+	if !ok {
+		return db, nil
+	}
+
+	if o.Host != "" {
+		db.Host = o.Host
+	}
+	if o.Port != 0 {
+		db.Port = o.Port
+	}
+	if o.User != "" {
+		db.User = o.User
+	}
+	if o.Database != "" {
+		db.Name = o.Database
+	}
+	if o.TLS != nil {
+		db.TLS = *o.TLS
+	}
+	if o.SecretRef != "" {
+		password, err := config.ResolveSecret(o.SecretRef, c.lookupEnv)
+		if err != nil {
+			return config.Database{}, fmt.Errorf("failed to resolve password for org %s: %w", name, err)
+		}
+		db.SetPassword(password)
+	}
+
+	return db, nil
+}
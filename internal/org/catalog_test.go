@@ -0,0 +1,143 @@
+package org
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/vivek-shah-13/store/internal/config"
+	"gotest.tools/v3/assert"
+)
+
+func TestCatalog_Connection(t *testing.T) {
+	t.Parallel()
+
+	secret := t.TempDir() + "/google"
+	if err := ioutil.WriteFile(secret, []byte("google-password\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Orgs = map[string]*config.OrgConnection{
+		"google": {
+			Host:      "google.db.internal",
+			Port:      3307,
+			User:      "google",
+			SecretRef: "file:" + secret,
+			Database:  "google_store",
+			TLS:       &config.TLS{Mode: "required"},
+		},
+		"microsoft": {
+			Host:      "microsoft.db.internal",
+			SecretRef: "env:MICROSOFT_DB_PASSWORD",
+		},
+	}
+
+	catalog := NewCatalog(cfg, func(key string) (string, bool) {
+		if key == "MICROSOFT_DB_PASSWORD" {
+			return "microsoft-password", true
+		}
+		return "", false
+	})
+
+	db, err := catalog.Connection("google")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, db, config.Database{
+		Driver:   config.DriverMySQL,
+		Path:     ".",
+		User:     "google",
+		Password: "google-password",
+		Host:     "google.db.internal",
+		Port:     3307,
+		Name:     "google_store",
+		TLS:      config.TLS{Mode: "required"},
+		Pool:     cfg.Database.Pool,
+	})
+
+	db, err = catalog.Connection("microsoft")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, db, config.Database{
+		Driver:   config.DriverMySQL,
+		Path:     ".",
+		User:     "admin",
+		Password: "microsoft-password",
+		Host:     "microsoft.db.internal",
+		Port:     3306,
+		Name:     "store_microsoft",
+		Pool:     cfg.Database.Pool,
+	})
+
+	db, err = catalog.Connection(Default)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, db, config.Database{
+		Driver:   config.DriverMySQL,
+		Path:     ".",
+		User:     "admin",
+		Password: "password123",
+		Host:     "localhost",
+		Port:     3306,
+		Name:     "store_default",
+		Pool:     cfg.Database.Pool,
+	})
+}
+
+func TestCatalog_Connection_unresolvableSecret_returnsAnError(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Orgs = map[string]*config.OrgConnection{
+		"google": {SecretRef: "env:GOOGLE_DB_PASSWORD"},
+	}
+
+	_, err := NewCatalog(cfg, func(string) (string, bool) { return "", false }).Connection("google")
+	assert.ErrorContains(t, err, "failed to resolve password for org google")
+}
+
+func TestCatalog_Replicas(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Orgs = map[string]*config.OrgConnection{
+		"google": {
+			Host:      "google-primary",
+			SecretRef: "env:GOOGLE_DB_PASSWORD",
+			Replicas: []*config.Replica{
+				{Host: "google-replica-1"},
+				{Host: "google-replica-2", User: "reader", SecretRef: "env:GOOGLE_READER_PASSWORD"},
+			},
+			MaxReplicaLag: config.Duration(10 * time.Second),
+		},
+		"microsoft": {
+			Replicas: []*config.Replica{{Host: "microsoft-replica"}},
+		},
+	}
+	catalog := NewCatalog(cfg, func(key string) (string, bool) {
+		switch key {
+		case "GOOGLE_DB_PASSWORD":
+			return "google-password", true
+		case "GOOGLE_READER_PASSWORD":
+			return "reader-password", true
+		}
+		return "", false
+	})
+
+	replicas, maxLag, err := catalog.Replicas("google")
+	assert.NilError(t, err)
+	assert.Equal(t, maxLag, 10*time.Second)
+	assert.Equal(t, len(replicas), 2)
+	assert.Equal(t, replicas[0].Host, "google-replica-1")
+	assert.Equal(t, replicas[0].User, "admin")
+	assert.Equal(t, replicas[0].Password, "google-password")
+	assert.Equal(t, replicas[0].Name, "store_google")
+	assert.Equal(t, replicas[0].Pool.ConnectRetries, 0)
+	assert.Equal(t, replicas[1].User, "reader")
+	assert.Equal(t, replicas[1].Password, "reader-password")
+
+	_, maxLag, err = catalog.Replicas("microsoft")
+	assert.NilError(t, err)
+	assert.Equal(t, maxLag, DefaultMaxReplicaLag)
+
+	replicas, _, err = catalog.Replicas(Default)
+	assert.NilError(t, err)
+	assert.Equal(t, len(replicas), 0)
+}
@@ -0,0 +1,94 @@
+package org
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/migration"
+)
+
+// Default is the org used when none is given. It is always registered so developers can work
+// without a migration state.
+const Default = "default"
+
+const MaxNameLength = 32
+
+var (
+	ErrInvalidName = errors.New("invalid org name")
+	ErrUnknownOrg  = errors.New("unknown org")
+)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reserved names are rejected because they are ambiguous on the command line or collide with
+// MySQL's own schemas.
+var reserved = map[string]bool{
+	"all":                true,
+	"none":               true,
+	"admin":              true,
+	"root":               true,
+	"mysql":              true,
+	"sys":                true,
+	"information_schema": true,
+	"performance_schema": true,
+}
+
+// ValidateName checks that name is safe to use as part of a database name.
+func ValidateName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name must not be empty", ErrInvalidName)
+	case len(name) > MaxNameLength:
+		return fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidName, name, MaxNameLength)
+	case !namePattern.MatchString(name):
+		return fmt.Errorf("%w: %q must start with a lowercase letter and contain only lowercase letters, digits and underscores", ErrInvalidName, name)
+	case reserved[name]:
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidName, name)
+	}
+
+	return nil
+}
+
+// DatabaseName returns the name of the database holding the org's data. name must already have
+// passed ValidateName.
+func DatabaseName(name string) string {
+	return "store_" + name
+}
+
+// Registry is the set of orgs known to the store.
+type Registry struct {
+	names map[string]bool
+}
+
+// NewRegistry returns a registry of the default org plus every org in state.
+func NewRegistry(state *migration.MigrationState) *Registry {
+	r := &Registry{names: map[string]bool{Default: true}}
+	for _, o := range state.Orgs {
+		r.names[o.Name] = true
+	}
+	return r
+}
+
+// Names returns the registered org names in sorted order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.names))
+	for name := range r.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup validates name and checks that it is registered.
+func (r *Registry) Lookup(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if !r.names[name] {
+		return fmt.Errorf("%w %q (valid orgs: %s)", ErrUnknownOrg, name, strings.Join(r.Names(), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package org
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vivek-shah-13/store/internal/migration"
+	"gotest.tools/v3/assert"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{"default", "google", "microsoft", "org_2", strings.Repeat("a", MaxNameLength)}
+	for _, name := range valid {
+		assert.NilError(t, ValidateName(name), name)
+	}
+
+	invalid := []string{
+		"",
+		"Google",
+		"2org",
+		"_org",
+		"org-name",
+		"foo`; DROP DATABASE store_google; --",
+		"foo?tls=false",
+		"mysql",
+		"information_schema",
+		strings.Repeat("a", MaxNameLength+1),
+	}
+	for _, name := range invalid {
+		err := ValidateName(name)
+		assert.Assert(t, errors.Is(err, ErrInvalidName), name)
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry(&migration.MigrationState{
+		Orgs: []*migration.OrgMigrationState{
+			{Name: "microsoft"},
+			{Name: "google"},
+		},
+	})
+
+	assert.DeepEqual(t, r.Names(), []string{"default", "google", "microsoft"})
+	assert.NilError(t, r.Lookup("google"))
+	assert.NilError(t, r.Lookup(Default))
+
+	err := r.Lookup("amazon")
+	assert.Assert(t, errors.Is(err, ErrUnknownOrg))
+	assert.Error(t, err, `unknown org "amazon" (valid orgs: default, google, microsoft)`)
+
+	err = r.Lookup("Amazon!")
+	assert.Assert(t, errors.Is(err, ErrInvalidName))
+}
@@ -0,0 +1,61 @@
+package org
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vivek-shah-13/store/internal/config"
+)
+
+// DefaultMaxReplicaLag is used for orgs with replicas which do not set MaxReplicaLag.
+const DefaultMaxReplicaLag = 5 * time.Second
+
+// Replicas returns the resolved connection settings of the org's read replicas, and the
+// maximum lag at which a replica may still serve reads.
+func (c *Catalog) Replicas(name string) ([]config.Database, time.Duration, error) {
+	primary, err := c.Connection(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	o, ok := c.orgs[name]
+	if !ok || len(o.Replicas) == 0 {
+		return nil, 0, nil
+	}
+
+	maxLag := time.Duration(o.MaxReplicaLag)
+	if maxLag == 0 {
+		maxLag = DefaultMaxReplicaLag
+	}
+
+	replicas := make([]config.Database, 0, len(o.Replicas))
+	for i, r := range o.Replicas {
+		db := primary
+		if r.Host != "" {
+			db.Host = r.Host
+		}
+		if r.Port != 0 {
+			db.Port = r.Port
+		}
+		if r.User != "" {
+			db.User = r.User
+		}
+		if r.TLS != nil {
+			db.TLS = *r.TLS
+		}
+		if r.SecretRef != "" {
+			password, err := config.ResolveSecret(r.SecretRef, c.lookupEnv)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to resolve password for replica %d of org %s: %w", i, name, err)
+			}
+			db.SetPassword(password)
+		}
+		// a replica which is down should fall back to the primary quickly, rather than
+		// retrying.
+		db.Pool.ConnectRetries = 0
+
+		replicas = append(replicas, db)
+	}
+
+	return replicas, maxLag, nil
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	Table  Format = "table"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+	YAML   Format = "yaml"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{Table, JSON, NDJSON, CSV, YAML}
+
+// ParseFormat returns the format named s. An empty s is Table.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return Table, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", errors.Errorf("unknown output format %q, must be one of %s", s, strings.Join(names, ", "))
+}
+
+// Column describes how a field of T is shown in table and CSV output.
+type Column[T any] struct {
+	// Name is the CSV header, which matches the field's JSON name.
+	Name string
+	// Header and Width are the table header and the minimum width of the column.
+	Header string
+	Width  int
+	Value  func(T) string
+}
+
+// Records writes records in format f. Table and CSV output show columns, while the other formats
+// marshal the records as they are.
+func Records[T any](w io.Writer, f Format, columns []Column[T], records []T) error {
+	switch f {
+	case Table:
+		return table(w, columns, records)
+	case CSV:
+		return csvRecords(w, columns, records)
+	case NDJSON:
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return Value(w, f, records)
+	}
+}
+
+// Value writes a single value which does not fit in columns, such as a record with its related
+// records, in format f. Table and CSV are not supported.
+func Value(w io.Writer, f Format, v any) error {
+	switch f {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case NDJSON:
+		return json.NewEncoder(w).Encode(v)
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return errors.Errorf("%s output is not supported here", f)
+	}
+}
+
+func table[T any](w io.Writer, columns []Column[T], records []T) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+
+	for _, c := range columns {
+		fmt.Fprintf(tw, "%-*s\t", c.Width, c.Header)
+	}
+	fmt.Fprintln(tw)
+
+	for _, r := range records {
+		for _, c := range columns {
+			fmt.Fprintf(tw, "%-*s\t", c.Width, c.Value(r))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+func csvRecords[T any](w io.Writer, columns []Column[T], records []T) error {
+	cw := csv.NewWriter(w)
+
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.Name
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		for i, c := range columns {
+			row[i] = c.Value(r)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
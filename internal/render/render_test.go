@@ -0,0 +1,59 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type record struct {
+	ID   int     `json:"id" yaml:"id"`
+	Name string  `json:"name" yaml:"name"`
+	Sku  *string `json:"sku" yaml:"sku"`
+}
+
+var columns = []Column[*record]{
+	{Name: "id", Header: "ID", Width: 3, Value: func(r *record) string { return "1" }},
+	{Name: "name", Header: "Name", Width: 6, Value: func(r *record) string { return r.Name }},
+}
+
+var records = []*record{{ID: 1, Name: "laptop, 13\""}}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	f, err := ParseFormat("")
+	assert.NilError(t, err)
+	assert.Equal(t, f, Table)
+
+	f, err = ParseFormat("yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, f, YAML)
+
+	_, err = ParseFormat("xml")
+	assert.ErrorContains(t, err, `unknown output format "xml", must be one of table, json, ndjson, csv, yaml`)
+}
+
+func TestRecords(t *testing.T) {
+	t.Parallel()
+
+	for format, want := range map[Format]string{
+		Table:  "ID  |Name        |\n1   |laptop, 13\" |\n",
+		CSV:    "id,name\n1,\"laptop, 13\"\"\"\n",
+		NDJSON: "{\"id\":1,\"name\":\"laptop, 13\\\"\",\"sku\":null}\n",
+		JSON:   "[\n  {\n    \"id\": 1,\n    \"name\": \"laptop, 13\\\"\",\n    \"sku\": null\n  }\n]\n",
+		YAML:   "- id: 1\n  name: laptop, 13\"\n  sku: null\n",
+	} {
+		var buf bytes.Buffer
+		assert.NilError(t, Records(&buf, format, columns, records))
+		assert.Equal(t, buf.String(), want, format)
+	}
+}
+
+func TestValue_table_returnsError(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	assert.ErrorContains(t, Value(&buf, Table, records[0]), "table output is not supported")
+}
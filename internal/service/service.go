@@ -0,0 +1,706 @@
+package service
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
+	"github.com/vivek-shah-13/store/internal/store"
+)
+
+// Service holds the business rules for customers, products and orders. Every front end goes
+// through it, so input is validated the same way whether it comes from the CLI or elsewhere.
+// Invalid input is reported as a *domain.ValidationError.
+type Service struct {
+	stores *store.Stores
+}
+
+func New(stores *store.Stores) *Service {
+	return &Service{stores: stores}
+}
+
+// ParseID parses the id of a record from user input.
+func ParseID(field, s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, domain.Invalid(field, "Must be valid integer")
+	}
+	return id, nil
+}
+
+// ParsePrice parses a price from user input, rounded to cents.
+func ParsePrice(s string) (float64, error) {
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+		return 0, domain.Invalid("price", "Price must be a number")
+	}
+	return math.Round(price*100) / 100, nil
+}
+
+// normalizeCustomer trims the customer's fields and upper cases the state code, then validates
+// the result.
+func normalizeCustomer(c *domain.Customer) error {
+	c.Email = strings.TrimSpace(c.Email)
+	c.State = strings.ToUpper(strings.TrimSpace(c.State))
+	return c.Validate()
+}
+
+// normalizeProduct trims the product's fields and rounds its price to cents, then validates the
+// result. An empty sku is stored as no sku.
+func normalizeProduct(p *domain.Product) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Price = math.Round(p.Price*100) / 100
+	if p.Sku != nil {
+		p.Sku = domain.OptionalString(strings.TrimSpace(*p.Sku))
+	}
+	return p.Validate()
+}
+
+// CreateCustomer creates a customer. The state code is stored in upper case.
+func (s *Service) CreateCustomer(ctx context.Context, email, state string) (*domain.Customer, error) {
+	c := &domain.Customer{Email: email, State: state}
+	if err := normalizeCustomer(c); err != nil {
+		return nil, err
+	}
+
+	if err := s.stores.Customers.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreateProduct creates a product. An empty sku creates a product without one.
+func (s *Service) CreateProduct(ctx context.Context, name string, price float64, sku string) (*domain.Product, error) {
+	p := &domain.Product{Name: name, Price: price, Sku: &sku}
+	if err := normalizeProduct(p); err != nil {
+		return nil, err
+	}
+
+	if err := s.stores.Products.Create(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreateOrder creates an order of a product by a customer.
+func (s *Service) CreateOrder(ctx context.Context, customerID, productID int) (*domain.Order, error) {
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	o := &domain.Order{CreatedAt: &createdAt, CustomerID: customerID, ProductID: productID}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkReferences(ctx, o); err != nil {
+		return nil, err
+	}
+
+	if err := s.stores.Orders.Create(ctx, o); err != nil {
+		if errors.Is(err, store.ErrMissingReference) {
+			// The customer or product was deleted after it was checked.
+			if refErr := s.checkReferences(ctx, o); refErr != nil {
+				return nil, refErr
+			}
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// CustomerUpdate holds the fields of a customer to change. Nil fields are left as they are.
+type CustomerUpdate struct {
+	Email *string
+	State *string
+}
+
+// UpdateCustomer applies u to the customer with id and returns the updated customer.
+func (s *Service) UpdateCustomer(ctx context.Context, id int, u CustomerUpdate) (*domain.Customer, error) {
+	c, err := s.stores.Customers.Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, domain.ErrCustomerNotFound)
+	}
+
+	if u.Email != nil {
+		c.Email = *u.Email
+	}
+	if u.State != nil {
+		c.State = *u.State
+	}
+	if err := normalizeCustomer(c); err != nil {
+		return nil, err
+	}
+
+	if err := s.stores.Customers.Update(ctx, c); err != nil {
+		return nil, notFound(err, domain.ErrCustomerNotFound)
+	}
+	return c, nil
+}
+
+// ProductUpdate holds the fields of a product to change. Nil fields are left as they are, and an
+// empty Sku removes the product's sku.
+type ProductUpdate struct {
+	Name  *string
+	Price *float64
+	Sku   *string
+}
+
+// UpdateProduct applies u to the product with id and returns the updated product.
+func (s *Service) UpdateProduct(ctx context.Context, id int, u ProductUpdate) (*domain.Product, error) {
+	p, err := s.stores.Products.Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, domain.ErrProductNotFound)
+	}
+
+	if u.Name != nil {
+		p.Name = *u.Name
+	}
+	if u.Price != nil {
+		p.Price = *u.Price
+	}
+	if u.Sku != nil {
+		p.Sku = u.Sku
+	}
+	if err := normalizeProduct(p); err != nil {
+		return nil, err
+	}
+
+	if err := s.stores.Products.Update(ctx, p); err != nil {
+		return nil, notFound(err, domain.ErrProductNotFound)
+	}
+	return p, nil
+}
+
+// OrderUpdate holds the fields of an order to change. Nil fields are left as they are.
+type OrderUpdate struct {
+	CustomerID *int
+	ProductID  *int
+}
+
+// UpdateOrder applies u to the order with id and returns the updated order.
+func (s *Service) UpdateOrder(ctx context.Context, id int, u OrderUpdate) (*domain.Order, error) {
+	o, err := s.stores.Orders.Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, domain.ErrOrderNotFound)
+	}
+
+	if u.CustomerID != nil {
+		o.CustomerID = *u.CustomerID
+	}
+	if u.ProductID != nil {
+		o.ProductID = *u.ProductID
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkReferences(ctx, o); err != nil {
+		return nil, err
+	}
+
+	if err := s.stores.Orders.Update(ctx, o); err != nil {
+		if errors.Is(err, store.ErrMissingReference) {
+			if refErr := s.checkReferences(ctx, o); refErr != nil {
+				return nil, refErr
+			}
+		}
+		return nil, notFound(err, domain.ErrOrderNotFound)
+	}
+	return o, nil
+}
+
+// PreviewDeleteCustomer returns the customer with id and the number of orders which deleting it
+// would delete too.
+func (s *Service) PreviewDeleteCustomer(ctx context.Context, id int) (*domain.Customer, int, error) {
+	c, err := s.stores.Customers.Get(ctx, id)
+	if err != nil {
+		return nil, 0, notFound(err, domain.ErrCustomerNotFound)
+	}
+
+	orders, err := s.stores.Orders.Count(ctx, store.OrderFilter{CustomerIDs: []int{id}})
+	if err != nil {
+		return nil, 0, err
+	}
+	return c, orders, nil
+}
+
+// DeleteCustomer deletes the customer with id along with their orders.
+func (s *Service) DeleteCustomer(ctx context.Context, id int) error {
+	return notFound(s.stores.Customers.Delete(ctx, id), domain.ErrCustomerNotFound)
+}
+
+// PreviewDeleteProduct returns the product with id and the number of orders which deleting it
+// would delete too.
+func (s *Service) PreviewDeleteProduct(ctx context.Context, id int) (*domain.Product, int, error) {
+	p, err := s.stores.Products.Get(ctx, id)
+	if err != nil {
+		return nil, 0, notFound(err, domain.ErrProductNotFound)
+	}
+
+	orders, err := s.stores.Orders.Count(ctx, store.OrderFilter{ProductIDs: []int{id}})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p, orders, nil
+}
+
+// DeleteProduct deletes the product with id along with its orders.
+func (s *Service) DeleteProduct(ctx context.Context, id int) error {
+	return notFound(s.stores.Products.Delete(ctx, id), domain.ErrProductNotFound)
+}
+
+// DeleteOrder deletes the order with id.
+func (s *Service) DeleteOrder(ctx context.Context, id int) error {
+	return notFound(s.stores.Orders.Delete(ctx, id), domain.ErrOrderNotFound)
+}
+
+// GetCustomer returns the customer with id.
+func (s *Service) GetCustomer(ctx context.Context, id int) (*domain.Customer, error) {
+	c, err := s.stores.Customers.Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, domain.ErrCustomerNotFound)
+	}
+	return c, nil
+}
+
+// GetCustomerByEmail returns the customer with email.
+func (s *Service) GetCustomerByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	c, err := s.stores.Customers.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return nil, notFound(err, domain.ErrCustomerNotFound)
+	}
+	return c, nil
+}
+
+// GetProduct returns the product with id.
+func (s *Service) GetProduct(ctx context.Context, id int) (*domain.Product, error) {
+	p, err := s.stores.Products.Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, domain.ErrProductNotFound)
+	}
+	return p, nil
+}
+
+// GetProductBySku returns the product with sku.
+func (s *Service) GetProductBySku(ctx context.Context, sku string) (*domain.Product, error) {
+	p, err := s.stores.Products.GetBySku(ctx, strings.TrimSpace(sku))
+	if err != nil {
+		return nil, notFound(err, domain.ErrProductNotFound)
+	}
+	return p, nil
+}
+
+// GetOrder returns the order with id.
+func (s *Service) GetOrder(ctx context.Context, id int) (*domain.Order, error) {
+	o, err := s.stores.Orders.Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, domain.ErrOrderNotFound)
+	}
+	return o, nil
+}
+
+// CustomerDetails is a customer together with their orders.
+type CustomerDetails struct {
+	Customer *domain.Customer `json:"customer" yaml:"customer"`
+	Orders   []*domain.Order  `json:"orders" yaml:"orders"`
+}
+
+// ProductDetails is a product together with its orders.
+type ProductDetails struct {
+	Product *domain.Product `json:"product" yaml:"product"`
+	Orders  []*domain.Order `json:"orders" yaml:"orders"`
+}
+
+// CustomerDetails returns c together with their orders.
+func (s *Service) CustomerDetails(ctx context.Context, c *domain.Customer) (*CustomerDetails, error) {
+	orders, err := s.stores.Orders.List(ctx, store.OrderFilter{CustomerIDs: []int{c.ID}}, store.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &CustomerDetails{Customer: c, Orders: orders}, nil
+}
+
+// ProductDetails returns p together with its orders.
+func (s *Service) ProductDetails(ctx context.Context, p *domain.Product) (*ProductDetails, error) {
+	orders, err := s.stores.Orders.List(ctx, store.OrderFilter{ProductIDs: []int{p.ID}}, store.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &ProductDetails{Product: p, Orders: orders}, nil
+}
+
+// OrderDetails returns the order with id together with its customer and product.
+func (s *Service) OrderDetails(ctx context.Context, id int) (*domain.OrderDetails, error) {
+	o, err := s.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.GetCustomer(ctx, o.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.GetProduct(ctx, o.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.OrderDetails{Order: o, Customer: c, Product: p}, nil
+}
+
+// checkReferences returns ErrCustomerNotFound or ErrProductNotFound unless the customer and the
+// product of o both exist.
+func (s *Service) checkReferences(ctx context.Context, o *domain.Order) error {
+	if _, err := s.stores.Customers.Get(ctx, o.CustomerID); err != nil {
+		return notFound(err, domain.ErrCustomerNotFound)
+	}
+	if _, err := s.stores.Products.Get(ctx, o.ProductID); err != nil {
+		return notFound(err, domain.ErrProductNotFound)
+	}
+	return nil
+}
+
+// notFound replaces store.ErrNotFound with the typed error for the record.
+func notFound(err, typed error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return typed
+	}
+	return err
+}
+
+// ParseDate parses the start of a date range from user input, either a date such as 2023-06-01
+// or an RFC 3339 time. With end set it parses the inclusive end of a range instead, and returns
+// the time just after it, so a date includes the whole day.
+func ParseDate(field, s string, end bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, domain.Invalid(field, "Must be a date like 2006-01-02 or a time like 2006-01-02T15:04:05Z")
+	}
+	t = t.UTC()
+	if end {
+		// Creation times are stored to the second.
+		t = t.Truncate(time.Second).Add(time.Second)
+	}
+	return t, nil
+}
+
+// ParseWhere parses a filter expression over the fields of schema from user input. An empty
+// expression is nil.
+func ParseWhere(s string, schema expr.Schema) (expr.Expr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	e, err := expr.Parse(s, schema)
+	if err != nil {
+		return nil, domain.Invalid("where", "Invalid filter expression at "+err.Error())
+	}
+	return e, nil
+}
+
+// ParseMatch returns a filter of field matching pattern in mode, one of store.MatchModes, from
+// user input. An empty mode is store.MatchContains.
+func ParseMatch(field, pattern, mode string) (store.Match, error) {
+	m := store.Match{Pattern: pattern, Mode: store.MatchMode(strings.ToLower(mode))}
+	return m, checkMatch(field, m)
+}
+
+// checkMatch validates the filter m of field.
+func checkMatch(field string, m store.Match) error {
+	if m.Mode == "" {
+		return nil
+	}
+
+	known := false
+	modes := make([]string, len(store.MatchModes))
+	for i, mode := range store.MatchModes {
+		known = known || mode == m.Mode
+		modes[i] = string(mode)
+	}
+	if !known {
+		return domain.Invalid(field, "Match mode must be one of "+strings.Join(modes, ", "))
+	}
+
+	if m.Mode == store.MatchRegex {
+		if _, err := regexp.Compile(m.Pattern); err != nil {
+			return domain.Invalid(field, "Pattern must be a valid regular expression: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// ParseSort parses a sort order of the form field[:asc|:desc] from user input, where field is
+// one of fields.
+func ParseSort(s string, fields []string) (store.Sort, error) {
+	field, direction, _ := strings.Cut(strings.TrimSpace(s), ":")
+	by := store.Sort{Field: strings.ToLower(field)}
+
+	switch strings.ToLower(direction) {
+	case "", "asc":
+	case "desc":
+		by.Desc = true
+	default:
+		return store.Sort{}, domain.Invalid("sort", "Sort direction must be asc or desc")
+	}
+
+	if by.Field == "" {
+		return by, nil
+	}
+	for _, f := range fields {
+		if f == by.Field {
+			return by, nil
+		}
+	}
+	return store.Sort{}, domain.Invalid("sort", "Sort field must be one of "+strings.Join(fields, ", "))
+}
+
+// checkListOptions validates opts for a store which can sort by fields.
+func checkListOptions(opts store.ListOptions, fields []string) error {
+	if _, err := ParseSort(opts.Sort.Field, fields); err != nil {
+		return err
+	}
+	if opts.Limit < 0 {
+		return domain.Invalid("limit", "Limit must not be negative")
+	}
+	if opts.Offset < 0 {
+		return domain.Invalid("offset", "Offset must not be negative")
+	}
+	if opts.After < 0 {
+		return domain.Invalid("after", "After must be a valid ID")
+	}
+	if opts.After != 0 && opts.Offset != 0 {
+		return domain.Invalid("after", "After can not be used together with offset")
+	}
+	if opts.After != 0 && opts.Sort.Field != "" && opts.Sort.Field != "id" {
+		return domain.Invalid("after", "After only works when sorting by id")
+	}
+	return nil
+}
+
+// checkIDs validates a filter of field by ids.
+func checkIDs(field string, ids []int) error {
+	for _, id := range ids {
+		if id <= 0 {
+			return domain.Invalid(field, "IDs must be positive")
+		}
+	}
+	return nil
+}
+
+// checkCustomerFilter validates f, upper casing the state codes of f.States.
+func checkCustomerFilter(f *store.CustomerFilter) error {
+	if err := checkIDs("id", f.IDs); err != nil {
+		return err
+	}
+	if err := checkMatch("email", f.Email); err != nil {
+		return err
+	}
+	if err := checkMatch("state", f.State); err != nil {
+		return err
+	}
+	// States are stored uppercase. Regular expressions are left alone, since uppercasing would
+	// change what classes such as \w or \s mean.
+	if f.State.Mode != store.MatchRegex {
+		f.State.Pattern = strings.ToUpper(strings.TrimSpace(f.State.Pattern))
+	}
+
+	states := make([]string, len(f.States))
+	for i, state := range f.States {
+		states[i] = strings.ToUpper(strings.TrimSpace(state))
+		if _, ok := domain.States[states[i]]; !ok {
+			return domain.Invalid("state", "State must be a valid U.S. State or Territory")
+		}
+	}
+	f.States = states
+	return nil
+}
+
+func checkProductFilter(f store.ProductFilter) error {
+	if err := checkIDs("id", f.IDs); err != nil {
+		return err
+	}
+	if err := checkMatch("name", f.Name); err != nil {
+		return err
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		return domain.Invalid("price", "Minimum price must not be above the maximum price")
+	}
+	return nil
+}
+
+func checkOrderFilter(f store.OrderFilter) error {
+	if err := checkIDs("id", f.IDs); err != nil {
+		return err
+	}
+	if err := checkIDs("customer_id", f.CustomerIDs); err != nil {
+		return err
+	}
+	if err := checkIDs("product_id", f.ProductIDs); err != nil {
+		return err
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedBefore.IsZero() && !f.CreatedFrom.Before(f.CreatedBefore) {
+		return domain.Invalid("created_at", "Created range must not be empty")
+	}
+	return nil
+}
+
+// ParseAggregation parses the group by field and aggregates of an aggregation from user input,
+// checking them against the fields of schema.
+func ParseAggregation(groupBy string, aggregates []store.Aggregate, schema expr.Schema) (store.Aggregation, error) {
+	a := store.Aggregation{GroupBy: groupBy, Aggregates: aggregates}
+	if err := checkAggregation(&a, schema); err != nil {
+		return store.Aggregation{}, err
+	}
+	return a, nil
+}
+
+// checkAggregation validates a against the fields of schema, lower casing its field names.
+// Aggregates only apply to number fields.
+func checkAggregation(a *store.Aggregation, schema expr.Schema) error {
+	a.GroupBy = strings.ToLower(strings.TrimSpace(a.GroupBy))
+	if _, ok := schema[a.GroupBy]; a.GroupBy != "" && !ok {
+		return domain.Invalid("group_by", "Group by field must be one of "+strings.Join(schema.Names(), ", "))
+	}
+
+	numbers := schema.Names(expr.Float)
+	aggregates := make([]store.Aggregate, len(a.Aggregates))
+	for i, agg := range a.Aggregates {
+		agg.Field = strings.ToLower(strings.TrimSpace(agg.Field))
+		known := false
+		funcs := make([]string, len(store.AggregateFuncs))
+		for i, fn := range store.AggregateFuncs {
+			known = known || fn == agg.Func
+			funcs[i] = string(fn)
+		}
+		if !known {
+			return domain.Invalid("aggregate", "Aggregate function must be one of "+strings.Join(funcs, ", "))
+		}
+
+		name := strings.ToUpper(string(agg.Func[:1])) + string(agg.Func[1:])
+		switch {
+		case len(numbers) == 0:
+			return domain.Invalid(string(agg.Func), name+" needs a number field and there are none")
+		case schema[agg.Field] != expr.Float:
+			return domain.Invalid(string(agg.Func), name+" field must be one of "+strings.Join(numbers, ", "))
+		}
+		aggregates[i] = agg
+	}
+	a.Aggregates = aggregates
+	return nil
+}
+
+// Page is one page of a list, along with the number of records matching the list's filter.
+type Page[T any] struct {
+	Total   int `json:"total" yaml:"total"`
+	Records []T `json:"records" yaml:"records"`
+}
+
+func (s *Service) ListCustomers(ctx context.Context, f store.CustomerFilter, opts store.ListOptions) (*Page[*domain.Customer], error) {
+	if err := checkListOptions(opts, store.CustomerSortFields); err != nil {
+		return nil, err
+	}
+	if err := checkCustomerFilter(&f); err != nil {
+		return nil, err
+	}
+	total, err := s.stores.Customers.Count(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	customers, err := s.stores.Customers.List(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*domain.Customer]{Total: total, Records: customers}, nil
+}
+
+func (s *Service) ListProducts(ctx context.Context, f store.ProductFilter, opts store.ListOptions) (*Page[*domain.Product], error) {
+	if err := checkListOptions(opts, store.ProductSortFields); err != nil {
+		return nil, err
+	}
+	if err := checkProductFilter(f); err != nil {
+		return nil, err
+	}
+	total, err := s.stores.Products.Count(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.stores.Products.List(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*domain.Product]{Total: total, Records: products}, nil
+}
+
+func (s *Service) ListOrders(ctx context.Context, f store.OrderFilter, opts store.ListOptions) (*Page[*domain.Order], error) {
+	if err := checkListOptions(opts, store.OrderSortFields); err != nil {
+		return nil, err
+	}
+	if err := checkOrderFilter(f); err != nil {
+		return nil, err
+	}
+	total, err := s.stores.Orders.Count(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.stores.Orders.List(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*domain.Order]{Total: total, Records: orders}, nil
+}
+
+func (s *Service) AggregateCustomers(ctx context.Context, f store.CustomerFilter, a store.Aggregation) ([]store.Group, error) {
+	if err := checkAggregation(&a, store.CustomerFields); err != nil {
+		return nil, err
+	}
+	if err := checkCustomerFilter(&f); err != nil {
+		return nil, err
+	}
+	return s.stores.Customers.Aggregate(ctx, f, a)
+}
+
+func (s *Service) AggregateProducts(ctx context.Context, f store.ProductFilter, a store.Aggregation) ([]store.Group, error) {
+	if err := checkAggregation(&a, store.ProductFields); err != nil {
+		return nil, err
+	}
+	if err := checkProductFilter(f); err != nil {
+		return nil, err
+	}
+	return s.stores.Products.Aggregate(ctx, f, a)
+}
+
+func (s *Service) AggregateOrders(ctx context.Context, f store.OrderFilter, a store.Aggregation) ([]store.Group, error) {
+	if err := checkAggregation(&a, store.OrderFields); err != nil {
+		return nil, err
+	}
+	if err := checkOrderFilter(f); err != nil {
+		return nil, err
+	}
+	return s.stores.Orders.Aggregate(ctx, f, a)
+}
+
+// ListOrderDetails lists orders like ListOrders, together with their customers and products.
+func (s *Service) ListOrderDetails(ctx context.Context, f store.OrderFilter, opts store.ListOptions) (*Page[*domain.OrderDetails], error) {
+	if err := checkListOptions(opts, store.OrderSortFields); err != nil {
+		return nil, err
+	}
+	if err := checkOrderFilter(f); err != nil {
+		return nil, err
+	}
+	total, err := s.stores.Orders.CountDetails(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.stores.Orders.ListDetails(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*domain.OrderDetails]{Total: total, Records: orders}, nil
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
+	"github.com/vivek-shah-13/store/internal/store"
+	"gotest.tools/v3/assert"
+)
+
+func assertValidationError(t *testing.T, err error, field string) {
+	t.Helper()
+
+	var validationErr *domain.ValidationError
+	assert.Assert(t, errors.As(err, &validationErr), "got %v", err)
+	assert.Equal(t, validationErr.Field, field)
+}
+
+func TestParsePrice(t *testing.T) {
+	t.Parallel()
+
+	price, err := ParsePrice("3.999")
+	assert.NilError(t, err)
+	assert.Equal(t, price, 4.0)
+
+	price, err = ParsePrice("3.99")
+	assert.NilError(t, err)
+	assert.Equal(t, price, 3.99)
+
+	_, err = ParsePrice("banana")
+	assertValidationError(t, err, "price")
+	_, err = ParsePrice("NaN")
+	assertValidationError(t, err, "price")
+}
+
+func TestParseID(t *testing.T) {
+	t.Parallel()
+
+	id, err := ParseID("customer_id", "12")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 12)
+
+	_, err = ParseID("customer_id", "4.5")
+	assertValidationError(t, err, "customer_id")
+	assert.ErrorContains(t, err, "Must be valid integer")
+}
+
+func TestService_CreateCustomer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, " vivek.s@outreach.io ", "wa")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, c, &domain.Customer{ID: 1, Email: "vivek.s@outreach.io", State: "WA"})
+
+	_, err = s.CreateCustomer(ctx, "vivek.s@outreach.io", "PP")
+	assertValidationError(t, err, "state")
+	_, err = s.CreateCustomer(ctx, "", "WA")
+	assertValidationError(t, err, "email")
+}
+
+func TestService_CreateProduct(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	p, err := s.CreateProduct(ctx, "laptop", 25.499, "")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p, &domain.Product{ID: 1, Name: "laptop", Price: 25.5})
+
+	_, err = s.CreateProduct(ctx, "laptop", -1, "abcde")
+	assertValidationError(t, err, "price")
+}
+
+func TestService_CreateOrder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	p, err := s.CreateProduct(ctx, "laptop", 25, "abcde")
+	assert.NilError(t, err)
+
+	o, err := s.CreateOrder(ctx, c.ID, p.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, o.ID, 1)
+
+	_, err = s.CreateOrder(ctx, 0, p.ID)
+	assertValidationError(t, err, "customer_id")
+	_, err = s.CreateOrder(ctx, 99, p.ID)
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound), err)
+	_, err = s.CreateOrder(ctx, c.ID, 99)
+	assert.Assert(t, errors.Is(err, domain.ErrProductNotFound), err)
+}
+
+func TestService_UpdateCustomer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+
+	state := "ca"
+	c, err = s.UpdateCustomer(ctx, c.ID, CustomerUpdate{State: &state})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, c, &domain.Customer{ID: 1, Email: "vivek.s@outreach.io", State: "CA"})
+
+	state = "PP"
+	_, err = s.UpdateCustomer(ctx, c.ID, CustomerUpdate{State: &state})
+	assertValidationError(t, err, "state")
+
+	_, err = s.UpdateCustomer(ctx, 99, CustomerUpdate{State: &state})
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound), err)
+}
+
+func TestService_UpdateProduct(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	p, err := s.CreateProduct(ctx, "laptop", 25, "abcde")
+	assert.NilError(t, err)
+
+	price, sku := 30.0, ""
+	p, err = s.UpdateProduct(ctx, p.ID, ProductUpdate{Price: &price, Sku: &sku})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p, &domain.Product{ID: 1, Name: "laptop", Price: 30})
+
+	_, err = s.UpdateProduct(ctx, 99, ProductUpdate{Price: &price})
+	assert.Assert(t, errors.Is(err, domain.ErrProductNotFound), err)
+}
+
+func TestService_UpdateOrder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	p, err := s.CreateProduct(ctx, "laptop", 25, "")
+	assert.NilError(t, err)
+	book, err := s.CreateProduct(ctx, "book", 12, "")
+	assert.NilError(t, err)
+	o, err := s.CreateOrder(ctx, c.ID, p.ID)
+	assert.NilError(t, err)
+
+	o, err = s.UpdateOrder(ctx, o.ID, OrderUpdate{ProductID: &book.ID})
+	assert.NilError(t, err)
+	assert.Equal(t, o.ProductID, book.ID)
+
+	missing := 99
+	_, err = s.UpdateOrder(ctx, o.ID, OrderUpdate{CustomerID: &missing})
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound), err)
+	_, err = s.UpdateOrder(ctx, missing, OrderUpdate{ProductID: &book.ID})
+	assert.Assert(t, errors.Is(err, domain.ErrOrderNotFound), err)
+}
+
+func TestService_DeleteCustomer_deletesTheirOrders(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	p, err := s.CreateProduct(ctx, "laptop", 25, "")
+	assert.NilError(t, err)
+	_, err = s.CreateOrder(ctx, c.ID, p.ID)
+	assert.NilError(t, err)
+
+	_, orders, err := s.PreviewDeleteCustomer(ctx, c.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, orders, 1)
+
+	assert.NilError(t, s.DeleteCustomer(ctx, c.ID))
+	_, orders, err = s.PreviewDeleteProduct(ctx, p.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, orders, 0)
+
+	assert.Assert(t, errors.Is(s.DeleteCustomer(ctx, c.ID), domain.ErrCustomerNotFound))
+	_, _, err = s.PreviewDeleteCustomer(ctx, c.ID)
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound))
+}
+
+func TestService_Details(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	p, err := s.CreateProduct(ctx, "laptop", 25, "abcde")
+	assert.NilError(t, err)
+	o, err := s.CreateOrder(ctx, c.ID, p.ID)
+	assert.NilError(t, err)
+
+	customer, err := s.CustomerDetails(ctx, c)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, customer.Orders, []*domain.Order{o})
+
+	product, err := s.GetProductBySku(ctx, " abcde ")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, product, p)
+
+	order, err := s.OrderDetails(ctx, o.ID)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, &domain.OrderDetails{Order: o, Customer: c, Product: p})
+
+	_, err = s.GetCustomerByEmail(ctx, "missing@outreach.io")
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound))
+}
+
+func TestParseSort(t *testing.T) {
+	t.Parallel()
+
+	by, err := ParseSort("Price:DESC", store.ProductSortFields)
+	assert.NilError(t, err)
+	assert.Equal(t, by, store.Sort{Field: "price", Desc: true})
+
+	by, err = ParseSort("", store.ProductSortFields)
+	assert.NilError(t, err)
+	assert.Equal(t, by, store.Sort{})
+
+	_, err = ParseSort("color", store.ProductSortFields)
+	assertValidationError(t, err, "sort")
+	assert.ErrorContains(t, err, "Sort field must be one of id, name, price, sku")
+	_, err = ParseSort("price:up", store.ProductSortFields)
+	assertValidationError(t, err, "sort")
+}
+
+func TestService_ListOrders(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	p, err := s.CreateProduct(ctx, "laptop", 25, "")
+	assert.NilError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = s.CreateOrder(ctx, c.ID, p.ID)
+		assert.NilError(t, err)
+	}
+
+	page, err := s.ListOrders(ctx, store.OrderFilter{CustomerIDs: []int{c.ID}}, store.ListOptions{Limit: 2, After: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, page.Total, 3)
+	assert.Equal(t, len(page.Records), 2)
+	assert.Equal(t, page.Records[0].ID, 2)
+
+	_, err = s.ListOrders(ctx, store.OrderFilter{}, store.ListOptions{Limit: -1})
+	assertValidationError(t, err, "limit")
+	_, err = s.ListOrders(ctx, store.OrderFilter{}, store.ListOptions{After: 1, Offset: 1})
+	assertValidationError(t, err, "after")
+	_, err = s.ListOrders(ctx, store.OrderFilter{}, store.ListOptions{After: 1, Sort: store.Sort{Field: "customer_id"}})
+	assertValidationError(t, err, "after")
+}
+
+func TestService_AggregateOrders(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	c, err := s.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	p, err := s.CreateProduct(ctx, "laptop", 25, "")
+	assert.NilError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = s.CreateOrder(ctx, c.ID, p.ID)
+		assert.NilError(t, err)
+	}
+
+	sum := 50.0
+	groups, err := s.AggregateOrders(ctx, store.OrderFilter{}, store.Aggregation{
+		GroupBy:    " Customer.State",
+		Aggregates: []store.Aggregate{{Func: store.Sum, Field: "PRODUCT.PRICE"}},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, groups, []store.Group{{Key: "WA", Count: 2, Values: []*float64{&sum}}})
+
+	_, err = s.AggregateOrders(ctx, store.OrderFilter{}, store.Aggregation{GroupBy: "colour"})
+	assertValidationError(t, err, "group_by")
+	_, err = s.AggregateOrders(ctx, store.OrderFilter{}, store.Aggregation{Aggregates: []store.Aggregate{{Func: store.Avg, Field: "id"}}})
+	assertValidationError(t, err, "avg")
+	assert.ErrorContains(t, err, "Avg field must be one of product.price")
+	_, err = s.AggregateCustomers(ctx, store.CustomerFilter{}, store.Aggregation{Aggregates: []store.Aggregate{{Func: store.Max, Field: "id"}}})
+	assertValidationError(t, err, "max")
+}
+
+func TestParseMatch(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseMatch("name", "M%", "LIKE")
+	assert.NilError(t, err)
+	assert.Equal(t, m, store.Match{Pattern: "M%", Mode: store.MatchLike})
+
+	_, err = ParseMatch("name", "M%", "fuzzy")
+	assertValidationError(t, err, "name")
+	assert.ErrorContains(t, err, "Match mode must be one of exact, prefix, contains, like, glob, regex")
+	_, err = ParseMatch("email", "(", "regex")
+	assertValidationError(t, err, "email")
+}
+
+func TestParseDate(t *testing.T) {
+	t.Parallel()
+
+	from, err := ParseDate("created_from", "2023-06-01", false)
+	assert.NilError(t, err)
+	assert.Equal(t, from, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	to, err := ParseDate("created_to", "2023-06-01", true)
+	assert.NilError(t, err)
+	assert.Equal(t, to, time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC))
+
+	to, err = ParseDate("created_to", "2023-06-01T14:30:00+02:00", true)
+	assert.NilError(t, err)
+	assert.Equal(t, to, time.Date(2023, 6, 1, 12, 30, 1, 0, time.UTC))
+
+	_, err = ParseDate("created_from", "June 1st", false)
+	assertValidationError(t, err, "created_from")
+}
+
+func TestParseWhere(t *testing.T) {
+	t.Parallel()
+
+	where, err := ParseWhere("", store.OrderFields)
+	assert.NilError(t, err)
+	assert.Assert(t, where == nil)
+
+	where, err = ParseWhere("product.price > 10", store.OrderFields)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, where, &expr.Compare{Field: "product.price", Op: ">", Value: 10.0})
+
+	_, err = ParseWhere("product.price > ten", store.OrderFields)
+	assertValidationError(t, err, "where")
+	assert.ErrorContains(t, err, "column 17")
+}
+
+func TestService_ListCustomers_filters(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New(store.NewMemory())
+
+	for _, state := range []string{"WA", "CA", "OR"} {
+		_, err := s.CreateCustomer(ctx, strings.ToLower(state)+"@outreach.io", state)
+		assert.NilError(t, err)
+	}
+
+	page, err := s.ListCustomers(ctx, store.CustomerFilter{States: []string{" wa", "or"}}, store.ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, page.Total, 2)
+	for _, test := range []struct {
+		state store.Match
+		want  int
+	}{
+		{state: store.Match{Pattern: "wa"}, want: 1},
+		{state: store.Match{Pattern: " ca", Mode: store.MatchExact}, want: 1},
+		{state: store.Match{Pattern: "o%", Mode: store.MatchLike}, want: 1},
+		{state: store.Match{Pattern: "a", Mode: store.MatchContains}, want: 2},
+		{state: store.Match{Pattern: "^w", Mode: store.MatchRegex}, want: 0},
+		{state: store.Match{Pattern: "^W", Mode: store.MatchRegex}, want: 1},
+	} {
+		page, err := s.ListCustomers(ctx, store.CustomerFilter{State: test.state}, store.ListOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, page.Total, test.want, test.state)
+	}
+
+	_, err = s.ListCustomers(ctx, store.CustomerFilter{States: []string{"PP"}}, store.ListOptions{})
+	assertValidationError(t, err, "state")
+	_, err = s.ListCustomers(ctx, store.CustomerFilter{IDs: []int{0}}, store.ListOptions{})
+	assertValidationError(t, err, "id")
+
+	min, max := 10.0, 5.0
+	_, err = s.ListProducts(ctx, store.ProductFilter{MinPrice: &min, MaxPrice: &max}, store.ListOptions{})
+	assertValidationError(t, err, "price")
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchMode says how a filter's pattern is compared with a field.
+type MatchMode string
+
+const (
+	// MatchContains matches fields containing the pattern. It is the default.
+	MatchContains MatchMode = "contains"
+	MatchExact    MatchMode = "exact"
+	MatchPrefix   MatchMode = "prefix"
+	// MatchLike treats the pattern as a SQL LIKE pattern, where % matches any text, _ any
+	// single character and \ escapes the next character.
+	MatchLike MatchMode = "like"
+	// MatchGlob treats the pattern as a glob, where * matches any text and ? any single
+	// character.
+	MatchGlob MatchMode = "glob"
+	// MatchRegex matches fields containing a match of the regular expression.
+	MatchRegex MatchMode = "regex"
+)
+
+// MatchModes lists every match mode.
+var MatchModes = []MatchMode{MatchExact, MatchPrefix, MatchContains, MatchLike, MatchGlob, MatchRegex}
+
+// Match selects the values of a text field matching Pattern. An empty Pattern matches every
+// value, and an empty Mode is MatchContains. Everything but the wildcards of like and glob
+// patterns is matched literally, and every mode compares case on every store.
+type Match struct {
+	Pattern string
+	Mode    MatchMode
+}
+
+// Contains returns a match for values containing s.
+func Contains(s string) Match {
+	return Match{Pattern: s, Mode: MatchContains}
+}
+
+// likePatternEscape is the escape character of like mode patterns, and likeEscape that of the
+// LIKE patterns built from the other modes.
+const (
+	likePatternEscape = '\\'
+	likeEscape        = '!'
+)
+
+// like returns the LIKE pattern of the contains, prefix and glob modes.
+func (m Match) like() string {
+	var b strings.Builder
+	if m.Mode == "" || m.Mode == MatchContains {
+		b.WriteByte('%')
+	}
+
+	escaped := false
+	for _, r := range m.Pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case m.Mode == MatchGlob && r == '\\':
+			escaped = true
+			continue
+		case m.Mode == MatchGlob && r == '*':
+			b.WriteByte('%')
+			continue
+		case m.Mode == MatchGlob && r == '?':
+			b.WriteByte('_')
+			continue
+		}
+		if r == '%' || r == '_' || r == likeEscape {
+			b.WriteRune(likeEscape)
+		}
+		b.WriteRune(r)
+	}
+
+	if m.Mode != MatchGlob {
+		b.WriteByte('%')
+	}
+	return b.String()
+}
+
+// regexp returns a regular expression matching the same values as m.
+func (m Match) regexp() (*regexp.Regexp, error) {
+	switch m.Mode {
+	case MatchRegex:
+		return regexp.Compile(m.Pattern)
+	case MatchExact:
+		return regexp.Compile("^" + regexp.QuoteMeta(m.Pattern) + "$")
+	}
+
+	// The other modes are LIKE patterns.
+	pattern, escape := m.Pattern, likePatternEscape
+	if m.Mode != MatchLike {
+		pattern, escape = m.like(), likeEscape
+	}
+
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		case r == escape:
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+// matcher returns a function reporting whether values match m, for the memory stores.
+func (m Match) matcher() (func(string) bool, error) {
+	if m.Pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	re, err := m.regexp()
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
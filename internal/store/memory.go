@@ -0,0 +1,711 @@
+package store
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
+)
+
+// NewMemory returns stores which keep everything in memory, for tests and experiments. They
+// behave like the SQL stores: ids start at 1, orders must refer to existing customers and
+// products, and deleting a customer or product deletes their orders.
+func NewMemory() *Stores {
+	m := &memory{
+		customers: map[int]domain.Customer{},
+		products:  map[int]domain.Product{},
+		orders:    map[int]domain.Order{},
+	}
+	return &Stores{
+		Customers: &memoryCustomers{m},
+		Products:  &memoryProducts{m},
+		Orders:    &memoryOrders{m},
+	}
+}
+
+type memory struct {
+	mu sync.Mutex
+
+	customers      map[int]domain.Customer
+	products       map[int]domain.Product
+	orders         map[int]domain.Order
+	lastCustomerID int
+	lastProductID  int
+	lastOrderID    int
+}
+
+// deleteOrdersWhere deletes the orders matching match, like ON DELETE CASCADE.
+func (m *memory) deleteOrdersWhere(match func(domain.Order) bool) {
+	for id, o := range m.orders {
+		if match(o) {
+			delete(m.orders, id)
+		}
+	}
+}
+
+// checkReferences returns an error unless the customer and product of o exist.
+func (m *memory) checkReferences(o *domain.Order) error {
+	if _, ok := m.customers[o.CustomerID]; !ok {
+		return errors.Wrapf(ErrMissingReference, "customer %d", o.CustomerID)
+	}
+	if _, ok := m.products[o.ProductID]; !ok {
+		return errors.Wrapf(ErrMissingReference, "product %d", o.ProductID)
+	}
+	return nil
+}
+
+// oneOf reports whether v is one of values, or true if there are none, like the IN conditions
+// of the SQL stores.
+func oneOf[T comparable](values []T, v T) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange reports whether min <= v <= max, ignoring nil bounds.
+func inRange(v float64, min, max *float64) bool {
+	return (min == nil || v >= *min) && (max == nil || v <= *max)
+}
+
+// createdBetween reports whether createdAt is within [from, before), ignoring zero bounds. A nil
+// createdAt is only within unbounded ranges, like NULL in SQL.
+func createdBetween(createdAt *time.Time, from, before time.Time) bool {
+	if from.IsZero() && before.IsZero() {
+		return true
+	}
+	return createdAt != nil && !createdAt.Before(from) && (before.IsZero() || createdAt.Before(before))
+}
+
+// where reports whether a record with the field values returned by value matches e, if e is not
+// nil.
+func where(e expr.Expr, value func(field string) any) bool {
+	return e == nil || expr.Eval(e, value)
+}
+
+func customerValues(c *domain.Customer) func(string) any {
+	return func(field string) any {
+		switch field {
+		case "id":
+			return c.ID
+		case "email":
+			return c.Email
+		case "state":
+			return c.State
+		}
+		return nil
+	}
+}
+
+func productValues(p *domain.Product) func(string) any {
+	return func(field string) any {
+		switch field {
+		case "id":
+			return p.ID
+		case "name":
+			return p.Name
+		case "price":
+			return p.Price
+		case "sku":
+			if p.Sku != nil {
+				return *p.Sku
+			}
+		}
+		return nil
+	}
+}
+
+// orderValues returns the fields of o, including those of its customer and product.
+func (m *memory) orderValues(o *domain.Order) func(string) any {
+	return func(field string) any {
+		if strings.HasPrefix(field, "customer.") {
+			c := m.customers[o.CustomerID]
+			return customerValues(&c)(strings.TrimPrefix(field, "customer."))
+		}
+		if strings.HasPrefix(field, "product.") {
+			p := m.products[o.ProductID]
+			return productValues(&p)(strings.TrimPrefix(field, "product."))
+		}
+
+		switch field {
+		case "id":
+			return o.ID
+		case "created_at":
+			if o.CreatedAt != nil {
+				return *o.CreatedAt
+			}
+		case "customer_id":
+			return o.CustomerID
+		case "product_id":
+			return o.ProductID
+		}
+		return nil
+	}
+}
+
+func sortedIDs[T any](records map[int]T) []int {
+	ids := make([]int, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// compare returns a negative number, zero or a positive number when a is less than, equal to
+// or greater than b.
+type compare[T any] func(a, b T) int
+
+func compareInts(a, b int) int {
+	return a - b
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// compareNullable compares a and b with compare, sorting nil after every value.
+func compareNullable[T any](a, b *T, compare compare[T]) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return compare(*a, *b)
+}
+
+// compareKeys compares group keys with the same Go type, sorting nil after every key.
+func compareKeys(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	switch a := a.(type) {
+	case int:
+		return compareInts(a, b.(int))
+	case float64:
+		return compareFloats(a, b.(float64))
+	case time.Time:
+		return compareTimes(a, b.(time.Time))
+	}
+	return strings.Compare(a.(string), b.(string))
+}
+
+// aggregate computes a over records, like the SQL stores. values returns the field values of a
+// record, which are checked against schema.
+func aggregate[T any](records []T, a Aggregation, schema expr.Schema, values func(T) func(string) any) ([]Group, error) {
+	if err := a.check(schema); err != nil {
+		return nil, err
+	}
+
+	rows := make([]func(string) any, len(records))
+	for i, r := range records {
+		rows[i] = values(r)
+	}
+	if a.GroupBy == "" {
+		return []Group{summarise(rows, a.Aggregates)}, nil
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareKeys(rows[i](a.GroupBy), rows[j](a.GroupBy)) < 0
+	})
+	groups := []Group{}
+	for start := 0; start < len(rows); {
+		key := rows[start](a.GroupBy)
+		end := start + 1
+		for end < len(rows) && compareKeys(key, rows[end](a.GroupBy)) == 0 {
+			end++
+		}
+		g := summarise(rows[start:end], a.Aggregates)
+		g.Key = key
+		groups = append(groups, g)
+		start = end
+	}
+	return groups, nil
+}
+
+// summarise returns the group of rows, skipping missing values like SQL's aggregate functions.
+func summarise(rows []func(string) any, aggregates []Aggregate) Group {
+	g := Group{Count: len(rows), Values: make([]*float64, len(aggregates))}
+	for i, agg := range aggregates {
+		var result *float64
+		n := 0
+		for _, value := range rows {
+			v, ok := value(agg.Field).(float64)
+			if !ok {
+				continue
+			}
+			n++
+			switch {
+			case result == nil:
+				result = &v
+			case agg.Func == Min:
+				*result = math.Min(*result, v)
+			case agg.Func == Max:
+				*result = math.Max(*result, v)
+			default:
+				*result += v
+			}
+		}
+		if result != nil && agg.Func == Avg {
+			*result /= float64(n)
+		}
+		g.Values[i] = result
+	}
+	return g
+}
+
+// page returns the page opts of records, which must be sorted by ID, like the SQL stores.
+func page[T any](records []T, opts ListOptions, fields map[string]compare[T], id func(T) int) ([]T, error) {
+	field := opts.Sort.Field
+	if field == "" {
+		field = "id"
+	}
+	compare, ok := fields[field]
+	if !ok {
+		return nil, errors.Errorf("cannot sort by %q", opts.Sort.Field)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		c := compare(records[i], records[j])
+		if c == 0 {
+			c = id(records[i]) - id(records[j])
+		}
+		if opts.Sort.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if opts.After != 0 {
+		after := records[:0]
+		for _, r := range records {
+			if (opts.Sort.Desc && id(r) < opts.After) || (!opts.Sort.Desc && id(r) > opts.After) {
+				after = append(after, r)
+			}
+		}
+		records = after
+	}
+
+	if opts.Offset >= len(records) {
+		return records[:0], nil
+	}
+	records = records[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(records) {
+		records = records[:opts.Limit]
+	}
+	return records, nil
+}
+
+type memoryCustomers struct{ *memory }
+
+var customerFields = map[string]compare[*domain.Customer]{
+	"id":    func(a, b *domain.Customer) int { return compareInts(a.ID, b.ID) },
+	"email": func(a, b *domain.Customer) int { return strings.Compare(a.Email, b.Email) },
+	"state": func(a, b *domain.Customer) int { return strings.Compare(a.State, b.State) },
+}
+
+func (m *memoryCustomers) Create(_ context.Context, c *domain.Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastCustomerID++
+	c.ID = m.lastCustomerID
+	m.customers[c.ID] = *c
+	return nil
+}
+
+func (m *memoryCustomers) Get(_ context.Context, id int) (*domain.Customer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.customers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+func (m *memoryCustomers) GetByEmail(_ context.Context, email string) (*domain.Customer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range sortedIDs(m.customers) {
+		if c := m.customers[id]; c.Email == email {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memoryCustomers) List(_ context.Context, f CustomerFilter, opts ListOptions) ([]*domain.Customer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	customers, err := m.filter(f)
+	if err != nil {
+		return nil, err
+	}
+	return page(customers, opts, customerFields, func(c *domain.Customer) int { return c.ID })
+}
+
+func (m *memoryCustomers) Count(_ context.Context, f CustomerFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	customers, err := m.filter(f)
+	return len(customers), err
+}
+
+func (m *memoryCustomers) Aggregate(_ context.Context, f CustomerFilter, a Aggregation) ([]Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	customers, err := m.filter(f)
+	if err != nil {
+		return nil, err
+	}
+	return aggregate(customers, a, CustomerFields, customerValues)
+}
+
+func (m *memoryCustomers) filter(f CustomerFilter) ([]*domain.Customer, error) {
+	email, err := f.Email.matcher()
+	if err != nil {
+		return nil, err
+	}
+	state, err := f.State.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	customers := []*domain.Customer{}
+	for _, id := range sortedIDs(m.customers) {
+		c := m.customers[id]
+		if oneOf(f.IDs, c.ID) && email(c.Email) && state(c.State) && oneOf(f.States, c.State) &&
+			where(f.Where, customerValues(&c)) {
+			customers = append(customers, &c)
+		}
+	}
+	return customers, nil
+}
+
+func (m *memoryCustomers) Update(_ context.Context, c *domain.Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.customers[c.ID]; !ok {
+		return ErrNotFound
+	}
+	m.customers[c.ID] = *c
+	return nil
+}
+
+func (m *memoryCustomers) Delete(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.customers[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.customers, id)
+	m.deleteOrdersWhere(func(o domain.Order) bool { return o.CustomerID == id })
+	return nil
+}
+
+type memoryProducts struct{ *memory }
+
+// copyProduct returns a copy of p which shares no memory with it, so that callers cannot change
+// a stored product through its Sku.
+func copyProduct(p domain.Product) *domain.Product {
+	if p.Sku != nil {
+		sku := *p.Sku
+		p.Sku = &sku
+	}
+	return &p
+}
+
+var productFields = map[string]compare[*domain.Product]{
+	"id":    func(a, b *domain.Product) int { return compareInts(a.ID, b.ID) },
+	"name":  func(a, b *domain.Product) int { return strings.Compare(a.Name, b.Name) },
+	"price": func(a, b *domain.Product) int { return compareFloats(a.Price, b.Price) },
+	"sku":   func(a, b *domain.Product) int { return compareNullable(a.Sku, b.Sku, strings.Compare) },
+}
+
+func (m *memoryProducts) Create(_ context.Context, p *domain.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastProductID++
+	p.ID = m.lastProductID
+	m.products[p.ID] = *copyProduct(*p)
+	return nil
+}
+
+func (m *memoryProducts) Get(_ context.Context, id int) (*domain.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyProduct(p), nil
+}
+
+func (m *memoryProducts) GetBySku(_ context.Context, sku string) (*domain.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range sortedIDs(m.products) {
+		if p := m.products[id]; p.Sku != nil && *p.Sku == sku {
+			return copyProduct(p), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memoryProducts) List(_ context.Context, f ProductFilter, opts ListOptions) ([]*domain.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	products, err := m.filter(f)
+	if err != nil {
+		return nil, err
+	}
+	return page(products, opts, productFields, func(p *domain.Product) int { return p.ID })
+}
+
+func (m *memoryProducts) Count(_ context.Context, f ProductFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	products, err := m.filter(f)
+	return len(products), err
+}
+
+func (m *memoryProducts) Aggregate(_ context.Context, f ProductFilter, a Aggregation) ([]Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	products, err := m.filter(f)
+	if err != nil {
+		return nil, err
+	}
+	return aggregate(products, a, ProductFields, productValues)
+}
+
+func (m *memoryProducts) filter(f ProductFilter) ([]*domain.Product, error) {
+	name, err := f.Name.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	products := []*domain.Product{}
+	for _, id := range sortedIDs(m.products) {
+		p := m.products[id]
+		if oneOf(f.IDs, p.ID) && name(p.Name) && inRange(p.Price, f.MinPrice, f.MaxPrice) &&
+			(f.HasSku == nil || *f.HasSku == (p.Sku != nil)) && where(f.Where, productValues(&p)) {
+			products = append(products, copyProduct(p))
+		}
+	}
+	return products, nil
+}
+
+func (m *memoryProducts) Update(_ context.Context, p *domain.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.products[p.ID]; !ok {
+		return ErrNotFound
+	}
+	m.products[p.ID] = *copyProduct(*p)
+	return nil
+}
+
+func (m *memoryProducts) Delete(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.products[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.products, id)
+	m.deleteOrdersWhere(func(o domain.Order) bool { return o.ProductID == id })
+	return nil
+}
+
+type memoryOrders struct{ *memory }
+
+// copyOrder returns a copy of o which shares no memory with it, so that callers cannot change a
+// stored order through its CreatedAt.
+func copyOrder(o domain.Order) *domain.Order {
+	if o.CreatedAt != nil {
+		createdAt := *o.CreatedAt
+		o.CreatedAt = &createdAt
+	}
+	return &o
+}
+
+var orderFields = map[string]compare[*domain.Order]{
+	"id": func(a, b *domain.Order) int { return compareInts(a.ID, b.ID) },
+	"created_at": func(a, b *domain.Order) int {
+		return compareNullable(a.CreatedAt, b.CreatedAt, compareTimes)
+	},
+	"customer_id": func(a, b *domain.Order) int { return compareInts(a.CustomerID, b.CustomerID) },
+	"product_id":  func(a, b *domain.Order) int { return compareInts(a.ProductID, b.ProductID) },
+}
+
+func (m *memoryOrders) Create(_ context.Context, o *domain.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkReferences(o); err != nil {
+		return err
+	}
+
+	m.lastOrderID++
+	o.ID = m.lastOrderID
+	m.orders[o.ID] = *copyOrder(*o)
+	return nil
+}
+
+func (m *memoryOrders) Get(_ context.Context, id int) (*domain.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyOrder(o), nil
+}
+
+func (m *memoryOrders) List(_ context.Context, f OrderFilter, opts ListOptions) ([]*domain.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return page(m.filter(f), opts, orderFields, func(o *domain.Order) int { return o.ID })
+}
+
+func (m *memoryOrders) ListDetails(_ context.Context, f OrderFilter, opts ListOptions) ([]*domain.OrderDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orders, err := page(m.joined(f), opts, orderFields, func(o *domain.Order) int { return o.ID })
+	if err != nil {
+		return nil, err
+	}
+	details := make([]*domain.OrderDetails, len(orders))
+	for i, o := range orders {
+		c := m.customers[o.CustomerID]
+		details[i] = &domain.OrderDetails{Order: o, Customer: &c, Product: copyProduct(m.products[o.ProductID])}
+	}
+	return details, nil
+}
+
+func (m *memoryOrders) CountDetails(_ context.Context, f OrderFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.joined(f)), nil
+}
+
+func (m *memoryOrders) Count(_ context.Context, f OrderFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.filter(f)), nil
+}
+
+func (m *memoryOrders) Aggregate(_ context.Context, f OrderFilter, a Aggregation) ([]Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return aggregate(m.filter(f), a, OrderFields, m.orderValues)
+}
+
+func (m *memoryOrders) filter(f OrderFilter) []*domain.Order {
+	orders := []*domain.Order{}
+	for _, id := range sortedIDs(m.orders) {
+		o := m.orders[id]
+		if oneOf(f.IDs, o.ID) && oneOf(f.CustomerIDs, o.CustomerID) && oneOf(f.ProductIDs, o.ProductID) &&
+			createdBetween(o.CreatedAt, f.CreatedFrom, f.CreatedBefore) && where(f.Where, m.orderValues(&o)) {
+			orders = append(orders, copyOrder(o))
+		}
+	}
+	return orders
+}
+
+// joined returns the orders matching f whose customer and product exist, like the inner joins of
+// the SQL stores.
+func (m *memoryOrders) joined(f OrderFilter) []*domain.Order {
+	orders := []*domain.Order{}
+	for _, o := range m.filter(f) {
+		_, hasCustomer := m.customers[o.CustomerID]
+		_, hasProduct := m.products[o.ProductID]
+		if hasCustomer && hasProduct {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+func (m *memoryOrders) Update(_ context.Context, o *domain.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[o.ID]; !ok {
+		return ErrNotFound
+	}
+	if err := m.checkReferences(o); err != nil {
+		return err
+	}
+	m.orders[o.ID] = *copyOrder(*o)
+	return nil
+}
+
+func (m *memoryOrders) Delete(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.orders, id)
+	return nil
+}
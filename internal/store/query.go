@@ -0,0 +1,183 @@
+package store
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/database"
+	"github.com/vivek-shah-13/store/internal/expr"
+)
+
+// sortColumn is a column a list can be sorted by.
+type sortColumn struct {
+	name     string
+	nullable bool
+}
+
+// listQuery builds the SELECT and COUNT statements of a list from its filter. Every value is
+// passed as a parameter, never written into the SQL.
+type listQuery struct {
+	dialect database.Dialect
+	table   string
+	// id is the qualified ID column of table, which stays unambiguous when other tables are
+	// joined.
+	id          string
+	joins       string
+	columns     string
+	sortColumns map[string]sortColumn
+
+	conditions []string
+	args       []any
+}
+
+func (s *sqlStore) newListQuery(table, columns string, sortColumns map[string]sortColumn) *listQuery {
+	return &listQuery{dialect: s.dialect, table: table, id: table + ".ID", columns: columns, sortColumns: sortColumns}
+}
+
+// where adds a condition with ? placeholders for args.
+func (q *listQuery) where(condition string, args ...any) {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+}
+
+// match selects the rows whose column matches m, if m has a pattern. Every mode compares case,
+// like the memory stores.
+func (q *listQuery) match(column string, m Match) {
+	if m.Pattern == "" {
+		return
+	}
+
+	switch m.Mode {
+	case MatchExact:
+		q.where(column+" = ?", m.Pattern)
+	case MatchLike:
+		q.where(q.dialect.Like(column, likePatternEscape), m.Pattern)
+	case MatchRegex:
+		q.where(q.dialect.Regexp(column), m.Pattern)
+	default:
+		q.where(q.dialect.Like(column, likeEscape), m.like())
+	}
+}
+
+// in selects the rows whose column is one of values, if there are any.
+func in[T any](q *listQuery, column string, values []T) {
+	if len(values) == 0 {
+		return
+	}
+
+	placeholders := make([]string, len(values))
+	args := make([]any, len(values))
+	for i, v := range values {
+		placeholders[i] = "?"
+		args[i] = v
+	}
+	q.where(column+" IN ("+strings.Join(placeholders, ", ")+")", args...)
+}
+
+// bound selects the rows where "column op value" holds, if value is not nil.
+func bound[T any](q *listQuery, column, op string, value *T) {
+	if value != nil {
+		q.where(column+" "+op+" ?", *value)
+	}
+}
+
+// present selects the rows whose column is set when present is true, or NULL when it is false,
+// if present is not nil.
+func (q *listQuery) present(column string, present *bool) {
+	switch {
+	case present == nil:
+	case *present:
+		q.where(column + " IS NOT NULL")
+	default:
+		q.where(column + " IS NULL")
+	}
+}
+
+// expr selects the rows matching e, if it is not nil. columns maps the fields of e to SQL
+// expressions.
+func (q *listQuery) expr(e expr.Expr, columns map[string]string) {
+	if e != nil {
+		condition, args := expr.SQL(e, func(field string) string { return columns[field] })
+		q.where(condition, args...)
+	}
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// count returns the statement counting the matching rows, and its arguments.
+func (q *listQuery) count() (string, []any) {
+	return "SELECT COUNT(*) FROM " + q.table + q.joins + q.whereClause(), q.args
+}
+
+// list returns the statement selecting the page opts of the matching rows, and its arguments.
+func (q *listQuery) list(opts ListOptions) (string, []any, error) {
+	column, ok := q.sortColumns[opts.Sort.Field]
+	if opts.Sort.Field == "" {
+		column, ok = q.sortColumns["id"], true
+	}
+	if !ok {
+		return "", nil, errors.Errorf("cannot sort by %q", opts.Sort.Field)
+	}
+
+	page := &listQuery{conditions: append([]string{}, q.conditions...), args: append([]any{}, q.args...)}
+	direction, after := " ASC", q.id+" > ?"
+	if opts.Sort.Desc {
+		direction, after = " DESC", q.id+" < ?"
+	}
+	if opts.After != 0 {
+		page.where(after, opts.After)
+	}
+
+	order := make([]string, 0, 3)
+	if column.nullable {
+		order = append(order, "("+column.name+" IS NULL)"+direction)
+	}
+	order = append(order, column.name+direction)
+	if column.name != q.id {
+		order = append(order, q.id+direction)
+	}
+	query := "SELECT " + q.columns + " FROM " + q.table + q.joins + page.whereClause() + " ORDER BY " + strings.Join(order, ", ")
+
+	// MySQL and SQLite only accept OFFSET after a LIMIT.
+	if opts.Limit > 0 || opts.Offset > 0 {
+		limit := opts.Limit
+		if limit == 0 {
+			limit = math.MaxInt64
+		}
+		query += " LIMIT ? OFFSET ?"
+		page.args = append(page.args, limit, opts.Offset)
+	}
+	return query, page.args, nil
+}
+
+// aggregate returns the statement computing a over the matching rows, and its arguments. It
+// selects the group key, if there is one, then the count and the aggregates. columns maps the
+// fields of a to SQL expressions.
+func (q *listQuery) aggregate(a Aggregation, columns map[string]string) (string, []any) {
+	// The fields are selected from a derived table so that the subqueries of joined fields can be
+	// grouped by name in every dialect.
+	fields, results := []string{q.id}, []string{"COUNT(*)"}
+	if a.GroupBy != "" {
+		fields = append(fields, columns[a.GroupBy]+" AS group_key")
+		results = append([]string{"group_key"}, results...)
+	}
+	for i, agg := range a.Aggregates {
+		name := "value" + strconv.Itoa(i)
+		fields = append(fields, columns[agg.Field]+" AS "+name)
+		results = append(results, strings.ToUpper(string(agg.Func))+"("+name+")")
+	}
+
+	query := "SELECT " + strings.Join(results, ", ") +
+		" FROM (SELECT " + strings.Join(fields, ", ") + " FROM " + q.table + q.joins + q.whereClause() + ") aggregated"
+	if a.GroupBy != "" {
+		query += " GROUP BY group_key ORDER BY (group_key IS NULL), group_key"
+	}
+	return query, q.args
+}
@@ -0,0 +1,477 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/database"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
+)
+
+// NewSQL returns stores backed by db. Queries are rewritten for the dialect of db, so this works
+// against MySQL as well as SQLite and PostgreSQL.
+func NewSQL(db *sql.DB) *Stores {
+	s := &sqlStore{db: db, dialect: database.DialectOf(db)}
+	return &Stores{
+		Customers: &sqlCustomers{s},
+		Products:  &sqlProducts{s},
+		Orders:    &sqlOrders{s},
+	}
+}
+
+type sqlStore struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+func (s *sqlStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+}
+
+func (s *sqlStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.Rebind(query), args...)
+}
+
+// exec runs a statement which affects the row with id, returning ErrNotFound if there is none.
+func (s *sqlStore) exec(ctx context.Context, table string, id int, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// MySQL only counts rows which actually changed, so an update which sets the current values
+	// affects no rows even though the row exists.
+	var exists int
+	err = s.queryRow(ctx, "SELECT 1 FROM "+table+" WHERE ID = ?", id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// count returns the number of rows matching q.
+func (s *sqlStore) count(ctx context.Context, q *listQuery) (int, error) {
+	query, args := q.count()
+	var n int
+	err := s.queryRow(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+// aggregate computes a over the rows matching q. columns and schema give the SQL expressions and
+// types of the fields of a.
+func (s *sqlStore) aggregate(ctx context.Context, q *listQuery, a Aggregation, columns map[string]string, schema expr.Schema) ([]Group, error) {
+	if err := a.check(schema); err != nil {
+		return nil, err
+	}
+	query, args := q.aggregate(a, columns)
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		g := Group{Values: make([]*float64, len(a.Aggregates))}
+		key := nullKey(schema[a.GroupBy])
+		values := make([]sql.NullFloat64, len(a.Aggregates))
+		dest := []any{&g.Count}
+		if a.GroupBy != "" {
+			dest = append([]any{key}, dest...)
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		if a.GroupBy != "" {
+			if g.Key, err = key.Value(); err != nil {
+				return nil, err
+			}
+			if id, ok := g.Key.(int64); ok {
+				g.Key = int(id)
+			}
+		}
+		for i, v := range values {
+			if v.Valid {
+				g.Values[i] = &values[i].Float64
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// nullKey returns a destination for a group key of type t, whose Value is the key with the Go
+// type of t, or nil.
+func nullKey(t expr.Type) interface {
+	sql.Scanner
+	driver.Valuer
+} {
+	switch t {
+	case expr.Int:
+		return &sql.NullInt64{}
+	case expr.Float:
+		return &sql.NullFloat64{}
+	case expr.Time:
+		return &sql.NullTime{}
+	}
+	return &sql.NullString{}
+}
+
+type sqlCustomers struct{ *sqlStore }
+
+const customerColumns = "ID, email, state"
+
+var customerWhereColumns = map[string]string{"id": "ID", "email": "email", "state": "state"}
+
+var customerSortColumns = map[string]sortColumn{
+	"id":    {name: "Customers.ID"},
+	"email": {name: "email"},
+	"state": {name: "state"},
+}
+
+func scanCustomer(row interface{ Scan(...any) error }) (*domain.Customer, error) {
+	var c domain.Customer
+	if err := row.Scan(&c.ID, &c.Email, &c.State); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *sqlCustomers) Create(ctx context.Context, c *domain.Customer) error {
+	id, err := database.Insert(ctx, s.db, "INSERT INTO Customers (email, state) VALUES (?, ?)", c.Email, c.State)
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	return nil
+}
+
+func (s *sqlCustomers) Get(ctx context.Context, id int) (*domain.Customer, error) {
+	c, err := scanCustomer(s.queryRow(ctx, "SELECT "+customerColumns+" FROM Customers WHERE ID = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+func (s *sqlCustomers) GetByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	c, err := scanCustomer(s.queryRow(ctx, "SELECT "+customerColumns+" FROM Customers WHERE email = ? ORDER BY ID LIMIT 1", email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+func (s *sqlCustomers) filter(f CustomerFilter) *listQuery {
+	q := s.newListQuery("Customers", customerColumns, customerSortColumns)
+	in(q, "ID", f.IDs)
+	q.match("email", f.Email)
+	q.match("state", f.State)
+	in(q, "state", f.States)
+	q.expr(f.Where, customerWhereColumns)
+	return q
+}
+
+func (s *sqlCustomers) List(ctx context.Context, f CustomerFilter, opts ListOptions) ([]*domain.Customer, error) {
+	query, args, err := s.filter(f).list(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := []*domain.Customer{}
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+func (s *sqlCustomers) Count(ctx context.Context, f CustomerFilter) (int, error) {
+	return s.count(ctx, s.filter(f))
+}
+
+func (s *sqlCustomers) Aggregate(ctx context.Context, f CustomerFilter, a Aggregation) ([]Group, error) {
+	return s.aggregate(ctx, s.filter(f), a, customerWhereColumns, CustomerFields)
+}
+
+func (s *sqlCustomers) Update(ctx context.Context, c *domain.Customer) error {
+	return s.exec(ctx, "Customers", c.ID, "UPDATE Customers SET email = ?, state = ? WHERE ID = ?", c.Email, c.State, c.ID)
+}
+
+func (s *sqlCustomers) Delete(ctx context.Context, id int) error {
+	return s.exec(ctx, "Customers", id, "DELETE FROM Customers WHERE ID = ?", id)
+}
+
+type sqlProducts struct{ *sqlStore }
+
+const productColumns = "ID, name, price, sku"
+
+var productWhereColumns = map[string]string{"id": "ID", "name": "name", "price": "price", "sku": "sku"}
+
+var productSortColumns = map[string]sortColumn{
+	"id":    {name: "Products.ID"},
+	"name":  {name: "name"},
+	"price": {name: "price"},
+	"sku":   {name: "sku", nullable: true},
+}
+
+func scanProduct(row interface{ Scan(...any) error }) (*domain.Product, error) {
+	var p domain.Product
+	if err := row.Scan(&p.ID, &p.Name, &p.Price, &p.Sku); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *sqlProducts) Create(ctx context.Context, p *domain.Product) error {
+	id, err := database.Insert(ctx, s.db, "INSERT INTO Products (name, price, sku) VALUES (?, ?, ?)", p.Name, p.Price, p.Sku)
+	if err != nil {
+		return err
+	}
+	p.ID = int(id)
+	return nil
+}
+
+func (s *sqlProducts) Get(ctx context.Context, id int) (*domain.Product, error) {
+	p, err := scanProduct(s.queryRow(ctx, "SELECT "+productColumns+" FROM Products WHERE ID = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+func (s *sqlProducts) GetBySku(ctx context.Context, sku string) (*domain.Product, error) {
+	p, err := scanProduct(s.queryRow(ctx, "SELECT "+productColumns+" FROM Products WHERE sku = ? ORDER BY ID LIMIT 1", sku))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+func (s *sqlProducts) filter(f ProductFilter) *listQuery {
+	q := s.newListQuery("Products", productColumns, productSortColumns)
+	in(q, "ID", f.IDs)
+	q.match("name", f.Name)
+	bound(q, "price", ">=", f.MinPrice)
+	bound(q, "price", "<=", f.MaxPrice)
+	q.present("sku", f.HasSku)
+	q.expr(f.Where, productWhereColumns)
+	return q
+}
+
+func (s *sqlProducts) List(ctx context.Context, f ProductFilter, opts ListOptions) ([]*domain.Product, error) {
+	query, args, err := s.filter(f).list(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*domain.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+func (s *sqlProducts) Count(ctx context.Context, f ProductFilter) (int, error) {
+	return s.count(ctx, s.filter(f))
+}
+
+func (s *sqlProducts) Aggregate(ctx context.Context, f ProductFilter, a Aggregation) ([]Group, error) {
+	return s.aggregate(ctx, s.filter(f), a, productWhereColumns, ProductFields)
+}
+
+func (s *sqlProducts) Update(ctx context.Context, p *domain.Product) error {
+	return s.exec(ctx, "Products", p.ID, "UPDATE Products SET name = ?, price = ?, sku = ? WHERE ID = ?", p.Name, p.Price, p.Sku, p.ID)
+}
+
+func (s *sqlProducts) Delete(ctx context.Context, id int) error {
+	return s.exec(ctx, "Products", id, "DELETE FROM Products WHERE ID = ?", id)
+}
+
+type sqlOrders struct{ *sqlStore }
+
+const orderColumns = "ID, created_at, customer_id, product_id"
+
+// orderWhereColumns reads the fields of an order's customer and product with subqueries rather
+// than joins, which keeps the column names of Orders unambiguous.
+var orderWhereColumns = map[string]string{
+	"id":             "Orders.ID",
+	"created_at":     "created_at",
+	"customer_id":    "customer_id",
+	"product_id":     "product_id",
+	"customer.id":    "customer_id",
+	"customer.email": "(SELECT email FROM Customers WHERE Customers.ID = Orders.customer_id)",
+	"customer.state": "(SELECT state FROM Customers WHERE Customers.ID = Orders.customer_id)",
+	"product.id":     "product_id",
+	"product.name":   "(SELECT name FROM Products WHERE Products.ID = Orders.product_id)",
+	"product.price":  "(SELECT price FROM Products WHERE Products.ID = Orders.product_id)",
+	"product.sku":    "(SELECT sku FROM Products WHERE Products.ID = Orders.product_id)",
+}
+
+var orderSortColumns = map[string]sortColumn{
+	"id":          {name: "Orders.ID"},
+	"created_at":  {name: "created_at", nullable: true},
+	"customer_id": {name: "customer_id"},
+	"product_id":  {name: "product_id"},
+}
+
+// orderDetailsColumns and orderDetailsJoins select an order with its customer and product.
+const (
+	orderDetailsColumns = "Orders.ID, Orders.created_at, Orders.customer_id, Orders.product_id, " +
+		"Customers.ID, Customers.email, Customers.state, Products.ID, Products.name, Products.price, Products.sku"
+	orderDetailsJoins = " JOIN Customers ON Customers.ID = Orders.customer_id JOIN Products ON Products.ID = Orders.product_id"
+)
+
+func scanOrder(row interface{ Scan(...any) error }) (*domain.Order, error) {
+	var o domain.Order
+	if err := row.Scan(&o.ID, &o.CreatedAt, &o.CustomerID, &o.ProductID); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *sqlOrders) Create(ctx context.Context, o *domain.Order) error {
+	id, err := database.Insert(ctx, s.db, "INSERT INTO Orders (created_at, customer_id, product_id) VALUES (?, ?, ?)", o.CreatedAt, o.CustomerID, o.ProductID)
+	if err != nil {
+		return orderError(err)
+	}
+	o.ID = int(id)
+	return nil
+}
+
+func (s *sqlOrders) Get(ctx context.Context, id int) (*domain.Order, error) {
+	o, err := scanOrder(s.queryRow(ctx, "SELECT "+orderColumns+" FROM Orders WHERE ID = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return o, err
+}
+
+func (s *sqlOrders) filter(f OrderFilter) *listQuery {
+	q := s.newListQuery("Orders", orderColumns, orderSortColumns)
+	in(q, q.id, f.IDs)
+	in(q, "customer_id", f.CustomerIDs)
+	in(q, "product_id", f.ProductIDs)
+	if !f.CreatedFrom.IsZero() {
+		q.where("created_at >= ?", f.CreatedFrom)
+	}
+	if !f.CreatedBefore.IsZero() {
+		q.where("created_at < ?", f.CreatedBefore)
+	}
+	q.expr(f.Where, orderWhereColumns)
+	return q
+}
+
+func (s *sqlOrders) List(ctx context.Context, f OrderFilter, opts ListOptions) ([]*domain.Order, error) {
+	query, args, err := s.filter(f).list(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*domain.Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (s *sqlOrders) ListDetails(ctx context.Context, f OrderFilter, opts ListOptions) ([]*domain.OrderDetails, error) {
+	q := s.filter(f)
+	q.columns, q.joins = orderDetailsColumns, orderDetailsJoins
+	query, args, err := q.list(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*domain.OrderDetails{}
+	for rows.Next() {
+		var o domain.Order
+		var c domain.Customer
+		var p domain.Product
+		if err := rows.Scan(&o.ID, &o.CreatedAt, &o.CustomerID, &o.ProductID, &c.ID, &c.Email, &c.State, &p.ID, &p.Name, &p.Price, &p.Sku); err != nil {
+			return nil, err
+		}
+		orders = append(orders, &domain.OrderDetails{Order: &o, Customer: &c, Product: &p})
+	}
+	return orders, rows.Err()
+}
+
+func (s *sqlOrders) CountDetails(ctx context.Context, f OrderFilter) (int, error) {
+	q := s.filter(f)
+	q.joins = orderDetailsJoins
+	return s.count(ctx, q)
+}
+
+func (s *sqlOrders) Count(ctx context.Context, f OrderFilter) (int, error) {
+	return s.count(ctx, s.filter(f))
+}
+
+func (s *sqlOrders) Aggregate(ctx context.Context, f OrderFilter, a Aggregation) ([]Group, error) {
+	return s.aggregate(ctx, s.filter(f), a, orderWhereColumns, OrderFields)
+}
+
+func (s *sqlOrders) Update(ctx context.Context, o *domain.Order) error {
+	return orderError(s.exec(ctx, "Orders", o.ID, "UPDATE Orders SET customer_id = ?, product_id = ? WHERE ID = ?", o.CustomerID, o.ProductID, o.ID))
+}
+
+// orderError marks foreign key violations on the Orders table with ErrMissingReference.
+func orderError(err error) error {
+	if database.IsForeignKeyViolation(err) {
+		return errors.Wrap(ErrMissingReference, err.Error())
+	}
+	return err
+}
+
+func (s *sqlOrders) Delete(ctx context.Context, id int) error {
+	return s.exec(ctx, "Orders", id, "DELETE FROM Orders WHERE ID = ?", id)
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
+)
+
+// ErrNotFound is returned when the record to get, update or delete does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrMissingReference is returned when an order refers to a customer or product which does not
+// exist.
+var ErrMissingReference = errors.New("referenced record does not exist")
+
+// CustomerFilter selects the customers matching every field which is set. The zero value
+// matches every customer.
+type CustomerFilter struct {
+	IDs   []int
+	Email Match
+	State Match
+	// States selects the customers in any of these states.
+	States []string
+	// Where is an expression over CustomerFields.
+	Where expr.Expr
+}
+
+// ProductFilter selects the products matching every field which is set. The zero value matches
+// every product.
+type ProductFilter struct {
+	IDs  []int
+	Name Match
+	// MinPrice and MaxPrice are inclusive.
+	MinPrice *float64
+	MaxPrice *float64
+	// HasSku selects the products with a sku when true, and those without one when false.
+	HasSku *bool
+	// Where is an expression over ProductFields.
+	Where expr.Expr
+}
+
+// OrderFilter selects the orders matching every field which is set. The zero value matches
+// every order.
+type OrderFilter struct {
+	IDs         []int
+	CustomerIDs []int
+	ProductIDs  []int
+	// CreatedFrom and CreatedBefore bound the creation time, including CreatedFrom but not
+	// CreatedBefore. Orders without a creation time only match when both are zero.
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+	// Where is an expression over OrderFields, which include the fields of the order's customer
+	// and product.
+	Where expr.Expr
+}
+
+// The fields of the Where expressions of each filter.
+var (
+	CustomerFields = expr.Schema{"id": expr.Int, "email": expr.String, "state": expr.String}
+	ProductFields  = expr.Schema{"id": expr.Int, "name": expr.String, "price": expr.Float, "sku": expr.String}
+	OrderFields    = expr.Schema{
+		"id":             expr.Int,
+		"created_at":     expr.Time,
+		"customer_id":    expr.Int,
+		"product_id":     expr.Int,
+		"customer.id":    expr.Int,
+		"customer.email": expr.String,
+		"customer.state": expr.String,
+		"product.id":     expr.Int,
+		"product.name":   expr.String,
+		"product.price":  expr.Float,
+		"product.sku":    expr.String,
+	}
+)
+
+// Sort orders a list by Field, one of the store's sort fields, breaking ties by ID. Missing
+// values sort last, or first when Desc is set.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions selects a page of a list. The zero value lists every record by ID.
+type ListOptions struct {
+	Sort Sort
+	// Limit is the most records to return, or 0 for no limit.
+	Limit  int
+	Offset int
+	// After continues a list from the record with this ID, the last one of the previous page. It
+	// only works when sorting by id.
+	After int
+}
+
+// The fields each store can sort by.
+var (
+	CustomerSortFields = []string{"id", "email", "state"}
+	ProductSortFields  = []string{"id", "name", "price", "sku"}
+	OrderSortFields    = []string{"id", "created_at", "customer_id", "product_id"}
+)
+
+// AggregateFunc summarises the values of a number field over a group of records. Missing
+// values are skipped, as in SQL.
+type AggregateFunc string
+
+const (
+	Sum AggregateFunc = "sum"
+	Avg AggregateFunc = "avg"
+	Min AggregateFunc = "min"
+	Max AggregateFunc = "max"
+)
+
+// AggregateFuncs lists every aggregate function.
+var AggregateFuncs = []AggregateFunc{Sum, Avg, Min, Max}
+
+// Aggregate applies Func to Field, one of the Float fields of the store's Where schema.
+type Aggregate struct {
+	Func  AggregateFunc
+	Field string
+}
+
+func (a Aggregate) String() string {
+	return string(a.Func) + "(" + a.Field + ")"
+}
+
+// Aggregation groups the records matching a filter by GroupBy, a field of the store's Where
+// schema, and computes Aggregates over each group. An empty GroupBy puts every record in one
+// group, even if there are none.
+type Aggregation struct {
+	GroupBy    string
+	Aggregates []Aggregate
+}
+
+// check returns an error unless the fields of a are in schema.
+func (a Aggregation) check(schema expr.Schema) error {
+	if _, ok := schema[a.GroupBy]; a.GroupBy != "" && !ok {
+		return errors.Errorf("cannot group by %q", a.GroupBy)
+	}
+	for _, agg := range a.Aggregates {
+		if !oneOf(AggregateFuncs, agg.Func) {
+			return errors.Errorf("unknown aggregate function %q", agg.Func)
+		}
+		if schema[agg.Field] != expr.Float {
+			return errors.Errorf("cannot aggregate %q", agg.Field)
+		}
+	}
+	return nil
+}
+
+// Group is one group of an Aggregation, in order of Key with missing keys last.
+type Group struct {
+	// Key is the value of the GroupBy field, with its Go type as in an expr.Expr, or nil if it
+	// is missing or there is no GroupBy.
+	Key   any
+	Count int
+	// Values holds the result of each aggregate, or nil if the group has no values to aggregate.
+	Values []*float64
+}
+
+// CustomerStore reads and writes customers. Create sets the ID of the new customer.
+type CustomerStore interface {
+	Create(ctx context.Context, c *domain.Customer) error
+	Get(ctx context.Context, id int) (*domain.Customer, error)
+	// GetByEmail returns the customer with email, or the first one if several share it.
+	GetByEmail(ctx context.Context, email string) (*domain.Customer, error)
+	List(ctx context.Context, f CustomerFilter, opts ListOptions) ([]*domain.Customer, error)
+	// Count returns the number of records matching f.
+	Count(ctx context.Context, f CustomerFilter) (int, error)
+	// Aggregate summarises the records matching f.
+	Aggregate(ctx context.Context, f CustomerFilter, a Aggregation) ([]Group, error)
+	Update(ctx context.Context, c *domain.Customer) error
+	Delete(ctx context.Context, id int) error
+}
+
+// ProductStore reads and writes products. Create sets the ID of the new product.
+type ProductStore interface {
+	Create(ctx context.Context, p *domain.Product) error
+	Get(ctx context.Context, id int) (*domain.Product, error)
+	// GetBySku returns the product with sku, or the first one if several share it.
+	GetBySku(ctx context.Context, sku string) (*domain.Product, error)
+	List(ctx context.Context, f ProductFilter, opts ListOptions) ([]*domain.Product, error)
+	// Count returns the number of records matching f.
+	Count(ctx context.Context, f ProductFilter) (int, error)
+	// Aggregate summarises the records matching f.
+	Aggregate(ctx context.Context, f ProductFilter, a Aggregation) ([]Group, error)
+	Update(ctx context.Context, p *domain.Product) error
+	Delete(ctx context.Context, id int) error
+}
+
+// OrderStore reads and writes orders. Create sets the ID of the new order. Deleting a customer
+// or product deletes their orders too.
+type OrderStore interface {
+	Create(ctx context.Context, o *domain.Order) error
+	Get(ctx context.Context, id int) (*domain.Order, error)
+	List(ctx context.Context, f OrderFilter, opts ListOptions) ([]*domain.Order, error)
+	// ListDetails lists orders like List, together with their customers and products.
+	ListDetails(ctx context.Context, f OrderFilter, opts ListOptions) ([]*domain.OrderDetails, error)
+	// Count returns the number of records matching f.
+	Count(ctx context.Context, f OrderFilter) (int, error)
+	// CountDetails returns the number of records ListDetails would list for f.
+	CountDetails(ctx context.Context, f OrderFilter) (int, error)
+	// Aggregate summarises the records matching f.
+	Aggregate(ctx context.Context, f OrderFilter, a Aggregation) ([]Group, error)
+	Update(ctx context.Context, o *domain.Order) error
+	Delete(ctx context.Context, id int) error
+}
+
+// Stores groups the stores of one org.
+type Stores struct {
+	Customers CustomerStore
+	Products  ProductStore
+	Orders    OrderStore
+}
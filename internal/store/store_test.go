@@ -0,0 +1,391 @@
+package store
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vivek-shah-13/store/internal/config"
+	"github.com/vivek-shah-13/store/internal/database"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
+	"gotest.tools/v3/assert"
+)
+
+// newSQLiteStores returns SQL stores over a fresh sqlite database with the schema from the
+// migrations.
+func newSQLiteStores(t *testing.T) *Stores {
+	ctx := context.Background()
+	conn := config.Default().Database
+	conn.Driver = config.DriverSQLite
+	conn.Path = t.TempDir()
+	conn.Name = "store_test"
+
+	assert.NilError(t, database.Create(ctx, conn))
+	db, err := database.Open(ctx, conn)
+	assert.NilError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	for _, file := range []string{"initial_0000.sqlite.sql", "addProductSku_0001.sql"} {
+		data, err := ioutil.ReadFile("../../migrations/" + file)
+		assert.NilError(t, err)
+		for _, statement := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			_, err := db.ExecContext(ctx, statement)
+			assert.NilError(t, err)
+		}
+	}
+
+	return NewSQL(db)
+}
+
+func TestStores(t *testing.T) {
+	t.Parallel()
+
+	for name, newStores := range map[string]func(*testing.T) *Stores{
+		"memory": func(*testing.T) *Stores { return NewMemory() },
+		"sqlite": newSQLiteStores,
+	} {
+		newStores := newStores
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testStores(t, newStores(t))
+		})
+		t.Run(name+"/paging", func(t *testing.T) {
+			t.Parallel()
+			testPaging(t, newStores(t))
+		})
+		t.Run(name+"/matching", func(t *testing.T) {
+			t.Parallel()
+			testMatching(t, newStores(t))
+		})
+		t.Run(name+"/filters", func(t *testing.T) {
+			t.Parallel()
+			testFilters(t, newStores(t))
+		})
+	}
+}
+
+func testStores(t *testing.T, s *Stores) {
+	ctx := context.Background()
+
+	wa := &domain.Customer{Email: "vivek.s@outreach.io", State: "WA"}
+	ca := &domain.Customer{Email: "v.s@example.com", State: "CA"}
+	assert.NilError(t, s.Customers.Create(ctx, wa))
+	assert.NilError(t, s.Customers.Create(ctx, ca))
+	assert.Equal(t, wa.ID, 1)
+	assert.Equal(t, ca.ID, 2)
+
+	laptop := &domain.Product{Name: "laptop", Price: 25.5, Sku: domain.OptionalString("abcde")}
+	book := &domain.Product{Name: "book", Price: 12}
+	assert.NilError(t, s.Products.Create(ctx, laptop))
+	assert.NilError(t, s.Products.Create(ctx, book))
+
+	order := &domain.Order{CustomerID: wa.ID, ProductID: laptop.ID}
+	assert.NilError(t, s.Orders.Create(ctx, order))
+	createdAt := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	dated := &domain.Order{CreatedAt: &createdAt, CustomerID: ca.ID, ProductID: book.ID}
+	assert.NilError(t, s.Orders.Create(ctx, dated))
+	err := s.Orders.Create(ctx, &domain.Order{CustomerID: 99, ProductID: book.ID})
+	assert.Assert(t, errors.Is(err, ErrMissingReference), err)
+
+	customers, err := s.Customers.List(ctx, CustomerFilter{Email: Contains("outreach")}, ListOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, customers, []*domain.Customer{wa})
+
+	products, err := s.Products.List(ctx, ProductFilter{}, ListOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, products, []*domain.Product{laptop, book})
+
+	orders, err := s.Orders.List(ctx, OrderFilter{CustomerIDs: []int{wa.ID}}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(orders), 1)
+	assert.Equal(t, orders[0].ProductID, laptop.ID)
+
+	wa.State = "OR"
+	assert.NilError(t, s.Customers.Update(ctx, wa))
+	assert.NilError(t, s.Customers.Update(ctx, wa))
+	got, err := s.Customers.Get(ctx, wa.ID)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got, wa)
+
+	got, err = s.Customers.GetByEmail(ctx, "v.s@example.com")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got, ca)
+	gotProduct, err := s.Products.GetBySku(ctx, "abcde")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, gotProduct, laptop)
+	_, err = s.Products.GetBySku(ctx, "missing")
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+
+	// Changing the records passed to or returned by a store must not change what it holds.
+	*laptop.Sku, *gotProduct.Sku = "fghij", "fghij"
+	gotProduct, err = s.Products.Get(ctx, laptop.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, *gotProduct.Sku, "abcde")
+	gotOrder, err := s.Orders.Get(ctx, dated.ID)
+	assert.NilError(t, err)
+	*dated.CreatedAt, *gotOrder.CreatedAt = time.Time{}, time.Time{}
+	gotOrder, err = s.Orders.Get(ctx, dated.ID)
+	assert.NilError(t, err)
+	assert.Assert(t, gotOrder.CreatedAt.Equal(time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)))
+
+	assert.Assert(t, errors.Is(s.Customers.Update(ctx, &domain.Customer{ID: 99}), ErrNotFound))
+	_, err = s.Products.Get(ctx, 99)
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+
+	assert.NilError(t, s.Customers.Delete(ctx, wa.ID))
+	_, err = s.Orders.Get(ctx, order.ID)
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+	assert.Assert(t, errors.Is(s.Customers.Delete(ctx, wa.ID), ErrNotFound))
+
+	orders, err = s.Orders.List(ctx, OrderFilter{}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(orders), 1)
+}
+
+func testPaging(t *testing.T, s *Stores) {
+	ctx := context.Background()
+
+	for _, p := range []*domain.Product{
+		{Name: "laptop", Price: 25, Sku: domain.OptionalString("b")},
+		{Name: "book", Price: 12},
+		{Name: "pen", Price: 12, Sku: domain.OptionalString("a")},
+		{Name: "lamp", Price: 40},
+	} {
+		assert.NilError(t, s.Products.Create(ctx, p))
+	}
+
+	ids := func(opts ListOptions) []int {
+		t.Helper()
+		products, err := s.Products.List(ctx, ProductFilter{}, opts)
+		assert.NilError(t, err)
+		ids := make([]int, len(products))
+		for i, p := range products {
+			ids[i] = p.ID
+		}
+		return ids
+	}
+
+	assert.DeepEqual(t, ids(ListOptions{Limit: 2}), []int{1, 2})
+	assert.DeepEqual(t, ids(ListOptions{Offset: 3}), []int{4})
+	assert.DeepEqual(t, ids(ListOptions{Limit: 2, After: 2}), []int{3, 4})
+	assert.DeepEqual(t, ids(ListOptions{Sort: Sort{Field: "id", Desc: true}, After: 3}), []int{2, 1})
+	assert.DeepEqual(t, ids(ListOptions{Sort: Sort{Field: "price"}}), []int{2, 3, 1, 4})
+	assert.DeepEqual(t, ids(ListOptions{Sort: Sort{Field: "price", Desc: true}, Limit: 3}), []int{4, 1, 3})
+	assert.DeepEqual(t, ids(ListOptions{Sort: Sort{Field: "sku"}}), []int{3, 1, 2, 4})
+	assert.DeepEqual(t, ids(ListOptions{Sort: Sort{Field: "sku", Desc: true}}), []int{4, 2, 1, 3})
+	assert.DeepEqual(t, ids(ListOptions{Offset: 10}), []int{})
+
+	_, err := s.Products.List(ctx, ProductFilter{}, ListOptions{Sort: Sort{Field: "color"}})
+	assert.ErrorContains(t, err, `cannot sort by "color"`)
+
+	n, err := s.Products.Count(ctx, ProductFilter{Name: Contains("l")})
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+}
+
+func testMatching(t *testing.T, s *Stores) {
+	ctx := context.Background()
+
+	for _, name := range []string{"Milk", "Cream", "M%", "M_lk", "Soy Milk", "Milk!"} {
+		assert.NilError(t, s.Products.Create(ctx, &domain.Product{Name: name, Price: 1}))
+	}
+
+	for _, tc := range []struct {
+		match Match
+		want  []string
+	}{
+		{Match{Pattern: "M%"}, []string{"M%"}},
+		{Match{Pattern: "ilk!"}, []string{"Milk!"}},
+		{Match{Pattern: "Milk", Mode: MatchExact}, []string{"Milk"}},
+		{Match{Pattern: "M_", Mode: MatchPrefix}, []string{"M_lk"}},
+		{Match{Pattern: "M%", Mode: MatchLike}, []string{"Milk", "M%", "M_lk", "Milk!"}},
+		{Match{Pattern: "M_lk", Mode: MatchLike}, []string{"Milk", "M_lk"}},
+		{Match{Pattern: "*Milk", Mode: MatchGlob}, []string{"Milk", "Soy Milk"}},
+		{Match{Pattern: "M?lk", Mode: MatchGlob}, []string{"Milk", "M_lk"}},
+		{Match{Pattern: `M\%`, Mode: MatchGlob}, []string{"M%"}},
+		{Match{Pattern: "^(Cream|Soy)", Mode: MatchRegex}, []string{"Cream", "Soy Milk"}},
+		// Every mode compares case, and like patterns escape with a backslash.
+		{Match{Pattern: "milk"}, []string{}},
+		{Match{Pattern: "milk", Mode: MatchExact}, []string{}},
+		{Match{Pattern: "cream", Mode: MatchPrefix}, []string{}},
+		{Match{Pattern: "m%", Mode: MatchLike}, []string{}},
+		{Match{Pattern: "*milk", Mode: MatchGlob}, []string{}},
+		{Match{Pattern: "^milk", Mode: MatchRegex}, []string{}},
+		{Match{Pattern: `M\%`, Mode: MatchLike}, []string{"M%"}},
+		{Match{Pattern: `M\_lk`, Mode: MatchLike}, []string{"M_lk"}},
+	} {
+		products, err := s.Products.List(ctx, ProductFilter{Name: tc.match}, ListOptions{})
+		assert.NilError(t, err)
+		names := make([]string, len(products))
+		for i, p := range products {
+			names[i] = p.Name
+		}
+		assert.DeepEqual(t, names, tc.want)
+	}
+}
+
+func testFilters(t *testing.T, s *Stores) {
+	ctx := context.Background()
+
+	for _, c := range []*domain.Customer{
+		{Email: "a@outreach.io", State: "WA"},
+		{Email: "b@outreach.io", State: "CA"},
+		{Email: "c@outreach.io", State: "OR"},
+	} {
+		assert.NilError(t, s.Customers.Create(ctx, c))
+	}
+	for _, p := range []*domain.Product{
+		{Name: "laptop", Price: 25, Sku: domain.OptionalString("abcde")},
+		{Name: "book", Price: 12},
+		{Name: "pen", Price: 2, Sku: domain.OptionalString("pen")},
+	} {
+		assert.NilError(t, s.Products.Create(ctx, p))
+	}
+	june := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	july := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	for _, o := range []*domain.Order{
+		{CreatedAt: &june, CustomerID: 1, ProductID: 1},
+		{CreatedAt: &july, CustomerID: 2, ProductID: 2},
+		{CustomerID: 3, ProductID: 3},
+	} {
+		assert.NilError(t, s.Orders.Create(ctx, o))
+	}
+
+	customers, err := s.Customers.List(ctx, CustomerFilter{States: []string{"WA", "OR"}, Email: Contains("outreach")}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(customers), 2)
+	assert.Equal(t, customers[1].State, "OR")
+
+	min, max, hasSku := 2.0, 12.0, true
+	products, err := s.Products.List(ctx, ProductFilter{MinPrice: &min, MaxPrice: &max}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(products), 2)
+	products, err = s.Products.List(ctx, ProductFilter{MaxPrice: &max, HasSku: &hasSku}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(products), 1)
+	assert.Equal(t, products[0].Name, "pen")
+	hasSku = false
+	n, err := s.Products.Count(ctx, ProductFilter{IDs: []int{1, 2}, HasSku: &hasSku})
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	orders, err := s.Orders.List(ctx, OrderFilter{CreatedFrom: june, CreatedBefore: july}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(orders), 1)
+	assert.Assert(t, orders[0].CreatedAt.Equal(june))
+	n, err = s.Orders.Count(ctx, OrderFilter{CreatedFrom: june})
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+	n, err = s.Orders.Count(ctx, OrderFilter{CustomerIDs: []int{1, 3}, ProductIDs: []int{3}})
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+	for input, want := range map[string]int{
+		"product.price > 10 and customer.state in (WA, CA)":       2,
+		"product.sku is null or customer.email = 'c@outreach.io'": 2,
+		"not (product.sku = abcde)":                               1,
+		"created_at >= 2023-06-15 or created_at is null":          2,
+	} {
+		where, err := expr.Parse(input, OrderFields)
+		assert.NilError(t, err)
+		n, err = s.Orders.Count(ctx, OrderFilter{Where: where})
+		assert.NilError(t, err)
+		assert.Equal(t, n, want, input)
+	}
+
+	where, err := expr.Parse("price < 20 and sku is not null", ProductFields)
+	assert.NilError(t, err)
+	products, err = s.Products.List(ctx, ProductFilter{Where: where}, ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(products), 1)
+	assert.Equal(t, products[0].Name, "pen")
+
+	where, err = expr.Parse("customer.state != CA", OrderFields)
+	assert.NilError(t, err)
+	details, err := s.Orders.ListDetails(ctx, OrderFilter{IDs: []int{1, 2, 3}, Where: where}, ListOptions{Sort: Sort{Field: "created_at", Desc: true}, Limit: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, len(details), 1)
+	assert.Equal(t, details[0].Order.ID, 3)
+	assert.Assert(t, details[0].Order.CreatedAt == nil)
+	assert.DeepEqual(t, details[0].Customer, &domain.Customer{ID: 3, Email: "c@outreach.io", State: "OR"})
+	assert.DeepEqual(t, details[0].Product, &domain.Product{ID: 3, Name: "pen", Price: 2, Sku: domain.OptionalString("pen")})
+	details, err = s.Orders.ListDetails(ctx, OrderFilter{}, ListOptions{After: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, len(details), 2)
+	n, err = s.Orders.CountDetails(ctx, OrderFilter{Where: where})
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+	assert.Assert(t, details[0].Order.CreatedAt.Equal(july))
+	assert.Equal(t, details[0].Customer.Email, "b@outreach.io")
+	assert.Equal(t, details[0].Product.Name, "book")
+
+	price := func(v float64) *float64 { return &v }
+	aggregates := []Aggregate{{Sum, "product.price"}, {Avg, "product.price"}, {Min, "product.price"}, {Max, "product.price"}}
+	for _, test := range []struct {
+		name      string
+		aggregate func() ([]Group, error)
+		want      []Group
+	}{
+		{
+			name: "customers by state",
+			aggregate: func() ([]Group, error) {
+				return s.Customers.Aggregate(ctx, CustomerFilter{}, Aggregation{GroupBy: "state"})
+			},
+			want: []Group{{Key: "CA", Count: 1, Values: []*float64{}}, {Key: "OR", Count: 1, Values: []*float64{}}, {Key: "WA", Count: 1, Values: []*float64{}}},
+		},
+		{
+			name: "products by sku",
+			aggregate: func() ([]Group, error) {
+				return s.Products.Aggregate(ctx, ProductFilter{}, Aggregation{GroupBy: "sku", Aggregates: []Aggregate{{Sum, "price"}}})
+			},
+			want: []Group{
+				{Key: "abcde", Count: 1, Values: []*float64{price(25)}},
+				{Key: "pen", Count: 1, Values: []*float64{price(2)}},
+				{Key: nil, Count: 1, Values: []*float64{price(12)}},
+			},
+		},
+		{
+			name: "orders by customer state",
+			aggregate: func() ([]Group, error) {
+				return s.Orders.Aggregate(ctx, OrderFilter{CustomerIDs: []int{1, 3}}, Aggregation{GroupBy: "customer.state", Aggregates: aggregates})
+			},
+			want: []Group{
+				{Key: "OR", Count: 1, Values: []*float64{price(2), price(2), price(2), price(2)}},
+				{Key: "WA", Count: 1, Values: []*float64{price(25), price(25), price(25), price(25)}},
+			},
+		},
+		{
+			name: "orders by product",
+			aggregate: func() ([]Group, error) {
+				return s.Orders.Aggregate(ctx, OrderFilter{CreatedFrom: june}, Aggregation{GroupBy: "product_id", Aggregates: aggregates[1:2]})
+			},
+			want: []Group{{Key: 1, Count: 1, Values: []*float64{price(25)}}, {Key: 2, Count: 1, Values: []*float64{price(12)}}},
+		},
+		{
+			name: "all orders",
+			aggregate: func() ([]Group, error) {
+				return s.Orders.Aggregate(ctx, OrderFilter{}, Aggregation{Aggregates: aggregates})
+			},
+			want: []Group{{Count: 3, Values: []*float64{price(39), price(13), price(2), price(25)}}},
+		},
+		{
+			name: "no orders",
+			aggregate: func() ([]Group, error) {
+				return s.Orders.Aggregate(ctx, OrderFilter{IDs: []int{10}}, Aggregation{Aggregates: aggregates[:1]})
+			},
+			want: []Group{{Count: 0, Values: []*float64{nil}}},
+		},
+	} {
+		groups, err := test.aggregate()
+		assert.NilError(t, err, test.name)
+		assert.DeepEqual(t, groups, test.want)
+	}
+	_, err = s.Products.Aggregate(ctx, ProductFilter{}, Aggregation{Aggregates: []Aggregate{{Sum, "name"}}})
+	assert.ErrorContains(t, err, `cannot aggregate "name"`)
+}
@@ -3,25 +3,36 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
 
 	"database/sql"
 	"log"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/urfave/cli/v2"
+	"github.com/vivek-shah-13/store/internal/archive"
+	"github.com/vivek-shah-13/store/internal/audit"
+	"github.com/vivek-shah-13/store/internal/config"
+	"github.com/vivek-shah-13/store/internal/database"
+	"github.com/vivek-shah-13/store/internal/domain"
+	"github.com/vivek-shah-13/store/internal/expr"
 	"github.com/vivek-shah-13/store/internal/migration"
+	"github.com/vivek-shah-13/store/internal/org"
+	"github.com/vivek-shah-13/store/internal/render"
+	"github.com/vivek-shah-13/store/internal/service"
+	"github.com/vivek-shah-13/store/internal/store"
 )
 
 type MigrationRunner struct {
@@ -32,95 +43,166 @@ func NewMigrationRunner(path string) *MigrationRunner {
 	return &MigrationRunner{path: path}
 }
 
-func (m *MigrationRunner) Run(ctx context.Context, conn *sql.Conn, lastRanId int) (int, error) {
+func (m *MigrationRunner) Run(ctx context.Context, conn *sql.Conn, dialect database.Dialect, lastRanId int) (int, error) {
+	org := &migration.OrgMigrationState{LastRanMigrationID: lastRanId}
+	err := m.run(ctx, conn, dialect, org)
+	return org.LastRanMigrationID, err
+}
+
+// run applies every migration newer than org.LastRanMigrationID, preferring the variants for
+// dialect, and records each one in the org's history as soon as it succeeds.
+func (m *MigrationRunner) run(ctx context.Context, conn *sql.Conn, dialect database.Dialect, org *migration.OrgMigrationState) error {
 	files, err := m.loadMigrationFiles()
 	if err != nil {
-		return lastRanId, err
+		return err
+	}
+
+	files, err = selectMigrations(files, dialect.Name())
+	if err != nil {
+		return err
 	}
 
-	sort.Slice(files, func(i, j int) bool {
-		id1, err := extractMigrationID(files[i])
+	for _, file := range files {
+		id, err := extractMigrationID(file)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		id2, err := extractMigrationID(files[j])
-		if err != nil {
-			log.Fatal(err)
+		if id <= org.LastRanMigrationID {
+			continue
 		}
 
-		return id1 < id2
-	})
-
-	fileSlice := files[lastRanId+1:]
-	log.Print(fileSlice)
-	updatedID := lastRanId
-
-	for _, file := range fileSlice {
-		readFile, err := os.Open(file)
-		if err != nil {
-			return updatedID, err
+		if err := m.execFile(ctx, conn, file); err != nil {
+			return err
 		}
-		defer readFile.Close()
-		fileScanner := bufio.NewScanner(readFile)
+		log.Printf("Executed migration: %s\n", file)
+		org.RecordApplied(id, filepath.Base(file), time.Now().UTC())
+	}
 
-		fileScanner.Split(bufio.ScanLines)
-		for fileScanner.Scan() {
+	return nil
+}
 
-			if strings.Trim(fileScanner.Text(), "") == "" {
-				continue
-			}
-			if _, err := conn.ExecContext(ctx, fileScanner.Text()); err != nil {
-				return updatedID, fmt.Errorf("failed to execute migration %s: %w", file, err)
-			}
-		}
-		log.Printf("Executed migration: %s\n", file)
-		updatedID, err = extractMigrationID(file)
+func (m *MigrationRunner) execFile(ctx context.Context, conn *sql.Conn, file string) error {
+	readFile, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer readFile.Close()
 
+	fileScanner := bufio.NewScanner(readFile)
+	fileScanner.Split(bufio.ScanLines)
+	for fileScanner.Scan() {
+		if strings.TrimSpace(fileScanner.Text()) == "" {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, fileScanner.Text()); err != nil {
+			return fmt.Errorf("failed to execute migration %s: %w", file, err)
+		}
 	}
 
-	return updatedID, nil
+	return fileScanner.Err()
 }
 
-// RunAll runs migrations for every org in orgs and then returns the updated migration state.
+// RunAll runs migrations for every org in state. The returned state is always usable: an org
+// which fails to migrate keeps the migrations it did apply and records the error.
 func (m *MigrationRunner) RunAll(ctx context.Context, state *migration.MigrationState) (*migration.MigrationState, error) {
 	for _, org := range state.Orgs {
-		// TODO: after each successful migration run, update the org.LastRanMigrationID
-
-		db, err := connectDB(org.Name)
-		if err != nil {
-			return nil, err
+		if err := m.runOrg(ctx, org); err != nil {
+			org.RecordFailure(err, time.Now().UTC())
+			return state, fmt.Errorf("failed to migrate org %s: %w", org.Name, err)
 		}
-		defer db.Close()
-		conn, err := db.Conn(ctx)
-		if err != nil {
-			return nil, err
-		}
-		defer conn.Close()
-		newID, err := m.Run(ctx, conn, org.LastRanMigrationID)
-		if err != nil {
-			return nil, err
-		}
-		org.LastRanMigrationID = newID
-
 	}
 
 	return state, nil
 }
 
+func (m *MigrationRunner) runOrg(ctx context.Context, org *migration.OrgMigrationState) error {
+	cfg, err := catalog.Connection(org.Name)
+	if err != nil {
+		return err
+	}
+	db, err := database.Open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return m.run(ctx, conn, database.DialectFor(cfg.Driver), org)
+}
+
+// migrationFilePattern matches <name>_<id>.sql and the per-dialect variant <name>_<id>.<dialect>.sql.
+var migrationFilePattern = regexp.MustCompile(`_(\d+)(?:\.([a-z0-9]+))?\.sql$`)
+
 func extractMigrationID(file string) (int, error) {
-	regex := regexp.MustCompile(`_(\d+)\.sql$`)
-	matches := regex.FindStringSubmatch(file)
+	id, _, err := parseMigrationFile(file)
+	return id, err
+}
 
-	if len(matches) != 2 {
-		return -1, errors.New("Didn't find match")
+func parseMigrationFile(file string) (int, string, error) {
+	matches := migrationFilePattern.FindStringSubmatch(file)
+
+	if len(matches) != 3 {
+		return -1, "", fmt.Errorf("Didn't find match in %s", file)
 	}
 
 	id, err := strconv.Atoi(matches[1])
 	if err != nil {
-		return -1, errors.New("Couldn't convert to integer")
+		return -1, "", errors.New("Couldn't convert to integer")
+	}
+
+	return id, matches[2], nil
+}
+
+// selectMigrations picks the file to run for each migration id, preferring the variant for
+// dialect over the generic file, and returns them sorted by id.
+func selectMigrations(files []string, dialect string) ([]string, error) {
+	type candidates struct {
+		generic, variant string
+	}
+	byID := map[int]*candidates{}
+
+	for _, file := range files {
+		id, fileDialect, err := parseMigrationFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if byID[id] == nil {
+			byID[id] = &candidates{}
+		}
+
+		switch fileDialect {
+		case "":
+			byID[id].generic = file
+		case dialect:
+			byID[id].variant = file
+		}
+	}
+
+	ids := make([]int, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	selected := make([]string, 0, len(ids))
+	for _, id := range ids {
+		c := byID[id]
+		switch {
+		case c.variant != "":
+			selected = append(selected, c.variant)
+		case c.generic != "":
+			selected = append(selected, c.generic)
+		default:
+			return nil, fmt.Errorf("migration %d has no file for dialect %s", id, dialect)
+		}
 	}
 
-	return id, nil
+	return selected, nil
 }
 
 func (m *MigrationRunner) loadMigrationFiles() ([]string, error) {
@@ -140,200 +222,362 @@ func (m *MigrationRunner) loadMigrationFiles() ([]string, error) {
 	return migrationFiles, nil
 }
 
-type Customer struct {
-	ID    int
-	Email string
-	State string
+const migrationsPath = "migrations"
+
+func connectDB(ctx context.Context, name string) (*sql.DB, error) {
+	db, err := catalog.Connection(name)
+	if err != nil {
+		return nil, err
+	}
+	return database.Open(ctx, db)
+}
+
+// connectReadDB connects to one of the org's read replicas which is within the allowed lag,
+// falling back to the primary when there are none.
+func connectReadDB(ctx context.Context, name string) (*sql.DB, error) {
+	replicas, maxLag, err := catalog.Replicas(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) == 0 {
+		return connectDB(ctx, name)
+	}
+
+	db, err := database.OpenReplica(ctx, replicas, maxLag)
+	if errors.Is(err, database.ErrNoReplicaAvailable) {
+		log.Println("no replica available, falling back to the primary")
+		return connectDB(ctx, name)
+	}
+	return db, err
+}
+
+var customerColumns = []render.Column[*domain.Customer]{
+	{Name: "id", Header: "ID", Width: 3, Value: func(c *domain.Customer) string { return strconv.Itoa(c.ID) }},
+	{Name: "email", Header: "Email", Width: 50, Value: func(c *domain.Customer) string { return c.Email }},
+	{Name: "state", Header: "State", Width: 2, Value: func(c *domain.Customer) string { return c.State }},
 }
 
-type Product struct {
-	ID    int
-	name  string
-	price float64
-	sku   sql.NullString
+var productColumns = []render.Column[*domain.Product]{
+	{Name: "id", Header: "ID", Width: 3, Value: func(p *domain.Product) string { return strconv.Itoa(p.ID) }},
+	{Name: "name", Header: "Name", Width: 25, Value: func(p *domain.Product) string { return p.Name }},
+	{Name: "price", Header: "Price", Width: 13, Value: func(p *domain.Product) string { return strconv.FormatFloat(p.Price, 'f', 2, 64) }},
+	{Name: "sku", Header: "Sku", Width: 25, Value: func(p *domain.Product) string { return p.SkuString() }},
 }
 
-type Order struct {
-	ID         int
-	created_at sql.NullString
-	cID        int
-	pID        int
+var orderColumns = []render.Column[*domain.Order]{
+	{Name: "id", Header: "OrderID", Width: 10, Value: func(o *domain.Order) string { return strconv.Itoa(o.ID) }},
+	{Name: "product_id", Header: "ProductID", Width: 12, Value: func(o *domain.Order) string { return strconv.Itoa(o.ProductID) }},
+	{Name: "customer_id", Header: "CustomerID", Width: 13, Value: func(o *domain.Order) string { return strconv.Itoa(o.CustomerID) }},
 }
 
-func NewCustomer(rows *sql.Rows) (*Customer, error) {
-	var c Customer
-	if err := rows.Scan(&c.ID, &c.Email, &c.State); err != nil {
-		return nil, err
-	}
+// orderDetailsColumns are the columns of show-orders --expand, named like the fields of --where.
+var orderDetailsColumns = []render.Column[*domain.OrderDetails]{
+	{Name: "id", Header: "OrderID", Width: 10, Value: func(o *domain.OrderDetails) string { return strconv.Itoa(o.Order.ID) }},
+	{Name: "created_at", Header: "CreatedAt", Width: 20, Value: func(o *domain.OrderDetails) string { return formatValue(o.Order.CreatedAt) }},
+	{Name: "customer.id", Header: "CustomerID", Width: 13, Value: func(o *domain.OrderDetails) string { return strconv.Itoa(o.Customer.ID) }},
+	{Name: "customer.email", Header: "Email", Width: 25, Value: func(o *domain.OrderDetails) string { return o.Customer.Email }},
+	{Name: "customer.state", Header: "State", Width: 5, Value: func(o *domain.OrderDetails) string { return o.Customer.State }},
+	{Name: "product.id", Header: "ProductID", Width: 12, Value: func(o *domain.OrderDetails) string { return strconv.Itoa(o.Product.ID) }},
+	{Name: "product.name", Header: "Name", Width: 25, Value: func(o *domain.OrderDetails) string { return o.Product.Name }},
+	{Name: "product.price", Header: "Price", Width: 13, Value: func(o *domain.OrderDetails) string { return formatValue(o.Product.Price) }},
+	{Name: "product.sku", Header: "Sku", Width: 25, Value: func(o *domain.OrderDetails) string { return o.Product.SkuString() }},
+}
 
-	return &c, nil
+func printCustomer(w io.Writer, customers ...*domain.Customer) {
+	render.Records(w, render.Table, customerColumns, customers)
 }
 
-func (c *Customer) Print(w *tabwriter.Writer) {
-	fmt.Fprintf(w, "%-*v\t%-*s\t%-*s\t\n", 3, c.ID, 50, c.Email, 2, c.State)
+func printOrder(w io.Writer, orders ...*domain.Order) {
+	render.Records(w, render.Table, orderColumns, orders)
 }
 
-func NewProduct(rows *sql.Rows) (*Product, error) {
-	var p Product
-	if err := rows.Scan(&p.ID, &p.name, &p.price, &p.sku); err != nil {
-		return nil, err
+func printProduct(w io.Writer, products ...*domain.Product) {
+	render.Records(w, render.Table, productColumns, products)
+}
+
+// outputFormat returns the format selected by the global --output flag.
+func outputFormat(cCtx *cli.Context) (render.Format, error) {
+	f, err := render.ParseFormat(cCtx.String("output"))
+	if err != nil {
+		return "", usageError(err.Error())
 	}
-	return &p, nil
+	return f, nil
 }
 
-func (p *Product) Print(w *tabwriter.Writer) {
-	fmt.Fprintf(w, "%-*v\t%-*s\t%-*.2f\t%-*s\t\n", 3, p.ID, 15, p.name, 13, p.price, 25, p.sku.String)
+// newOutputFlag returns the global --output flag read by outputFormat.
+func newOutputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "the output format, table, json, ndjson, csv or yaml",
+		Value:   string(render.Table),
+	}
 }
 
-func NewOrder(rows *sql.Rows) (*Order, error) {
-	var o Order
-	if err := rows.Scan(&o.ID, &o.created_at, &o.cID, &o.pID); err != nil {
-		return nil, err
+// renderRecords writes records in the format selected by --output.
+func renderRecords[T any](cCtx *cli.Context, columns []render.Column[T], records ...T) error {
+	f, err := outputFormat(cCtx)
+	if err != nil {
+		return err
 	}
-	return &o, nil
-}
-
-func (o *Order) Print(w *tabwriter.Writer) {
-	fmt.Fprintf(w, "%-*v\t%-*v\t%-*v\t\n", 3, o.ID, 12, o.pID, 13, o.cID)
-}
-
-var states = map[string]bool{
-	"AL": true,
-	"AK": true,
-	"AZ": true,
-	"AR": true,
-	"AS": true,
-	"CA": true,
-	"CO": true,
-	"CT": true,
-	"DE": true,
-	"DC": true,
-	"FL": true,
-	"GA": true,
-	"GU": true,
-	"HI": true,
-	"ID": true,
-	"IL": true,
-	"IN": true,
-	"IA": true,
-	"KS": true,
-	"KY": true,
-	"LA": true,
-	"ME": true,
-	"MD": true,
-	"MA": true,
-	"MI": true,
-	"MN": true,
-	"MS": true,
-	"MO": true,
-	"MT": true,
-	"NE": true,
-	"NV": true,
-	"NH": true,
-	"NJ": true,
-	"NM": true,
-	"NY": true,
-	"NC": true,
-	"ND": true,
-	"MP": true,
-	"OH": true,
-	"OK": true,
-	"OR": true,
-	"PA": true,
-	"PR": true,
-	"RI": true,
-	"SC": true,
-	"SD": true,
-	"TN": true,
-	"TX": true,
-	"VT": true,
-	"UT": true,
-	"VA": true,
-	"VI": true,
-	"WA": true,
-	"WV": true,
-	"WI": true,
-	"WY": true,
-}
-
-func connectDB(name string) (*sql.DB, error) {
-	cfg := mysql.Config{
-		User:   "admin",
-		Passwd: "password123",
-		Addr:   "localhost",
-		DBName: "store_" + name,
-	}
-
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+	return render.Records(os.Stdout, f, columns, records)
+}
+
+// renderPage writes a page of a show command in the format selected by --output. Table output
+// ends with the total number of matching records and JSON and YAML wrap the records in an
+// object holding the total. CSV and NDJSON only hold records, so the total is logged instead.
+func renderPage[T any](cCtx *cli.Context, columns []render.Column[T], page *service.Page[T], noun string) error {
+	f, err := outputFormat(cCtx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	summary := fmt.Sprintf("Showing %d of %d %s", len(page.Records), page.Total, noun)
+	switch f {
+	case render.Table:
+		if err := render.Records(os.Stdout, f, columns, page.Records); err != nil {
+			return err
+		}
+		fmt.Println(summary)
+		return nil
+	case render.JSON, render.YAML:
+		return render.Value(os.Stdout, f, page)
+	default:
+		log.Println(summary)
+		return render.Records(os.Stdout, f, columns, page.Records)
+	}
+}
+
+// matchFlag returns the flag selecting how the filter flag named field is matched, read by
+// matchArg.
+func matchFlag(field string) cli.Flag {
+	return &cli.StringFlag{
+		Name:  field + "-match",
+		Usage: "how --" + field + " is matched, exact, prefix, contains, like, glob or regex",
+		Value: string(store.MatchContains),
+	}
+}
+
+// matchArg returns the filter given by the flag named field and its matchFlag.
+func matchArg(cCtx *cli.Context, field string) (store.Match, error) {
+	return service.ParseMatch(field, cCtx.String(field), cCtx.String(field+"-match"))
+}
+
+// whereFlag returns the --where flag of a show command, read by whereArg.
+func whereFlag(example string) cli.Flag {
+	return &cli.StringFlag{
+		Name:  "where",
+		Usage: "a filter expression, such as \"" + example + "\"",
+	}
+}
+
+// whereArg returns the expression given by --where over the fields of schema, or nil if there is
+// none.
+func whereArg(cCtx *cli.Context, schema expr.Schema) (expr.Expr, error) {
+	return service.ParseWhere(cCtx.String("where"), schema)
+}
+
+// aggregateDescriptions completes the usage of the aggregate function flags.
+var aggregateDescriptions = map[store.AggregateFunc]string{
+	store.Sum: "total",
+	store.Avg: "average",
+	store.Min: "lowest",
+	store.Max: "highest",
+}
+
+// aggregateFlags returns the flags which summarise the records of a show command instead of
+// listing them, read by aggregationArg. The aggregate functions are only offered when schema has
+// number fields.
+func aggregateFlags(schema expr.Schema) []cli.Flag {
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "count",
+			Usage: "show the number of matching records instead of the records",
+		},
+		&cli.StringFlag{
+			Name:  "group-by",
+			Usage: "summarise the records for each value of a field, one of " + strings.Join(schema.Names(), ", "),
+		},
+	}
+
+	numbers := schema.Names(expr.Float)
+	if len(numbers) == 0 {
+		return flags
+	}
+	for _, fn := range store.AggregateFuncs {
+		flags = append(flags, &cli.StringFlag{
+			Name:  string(fn),
+			Usage: "show the " + aggregateDescriptions[fn] + " of a field, one of " + strings.Join(numbers, ", "),
+		})
+	}
+	return flags
+}
+
+// aggregationArg returns the aggregation given by aggregateFlags over the fields of schema, and
+// whether there is one. Summaries replace the records, so they can not be paged or sorted.
+func aggregationArg(cCtx *cli.Context, schema expr.Schema) (store.Aggregation, bool, error) {
+	var aggregates []store.Aggregate
+	for _, fn := range store.AggregateFuncs {
+		if field := cCtx.String(string(fn)); field != "" {
+			aggregates = append(aggregates, store.Aggregate{Func: fn, Field: field})
+		}
+	}
+	if !cCtx.Bool("count") && cCtx.String("group-by") == "" && len(aggregates) == 0 {
+		return store.Aggregation{}, false, nil
 	}
 
-	return db, nil
+	for _, name := range []string{"limit", "offset", "after", "sort"} {
+		if cCtx.IsSet(name) {
+			return store.Aggregation{}, false, usageError("Must not specify --" + name + " with --count, --group-by or an aggregate")
+		}
+	}
+	a, err := service.ParseAggregation(cCtx.String("group-by"), aggregates, schema)
+	return a, err == nil, err
 }
 
-func printCustomer(w io.Writer, customers ...*Customer) {
-	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+// renderGroups writes the groups of a in the format selected by --output. Each group has its
+// key, its count, when --count is set or there are no aggregates, and its aggregates, which are
+// named like sum(price).
+func renderGroups(cCtx *cli.Context, a store.Aggregation, groups []store.Group) error {
+	f, err := outputFormat(cCtx)
+	if err != nil {
+		return err
+	}
+
+	var columns []render.Column[map[string]any]
+	addColumn := func(name string) {
+		columns = append(columns, render.Column[map[string]any]{
+			Name:   name,
+			Header: name,
+			Width:  13,
+			Value:  func(row map[string]any) string { return formatValue(row[name]) },
+		})
+	}
+	count := cCtx.Bool("count") || len(a.Aggregates) == 0
+	if a.GroupBy != "" {
+		addColumn(a.GroupBy)
+	}
+	if count {
+		addColumn("count")
+	}
+	for _, agg := range a.Aggregates {
+		addColumn(agg.String())
+	}
+
+	rows := make([]map[string]any, len(groups))
+	for i, g := range groups {
+		rows[i] = map[string]any{}
+		if a.GroupBy != "" {
+			rows[i][a.GroupBy] = g.Key
+		}
+		if count {
+			rows[i]["count"] = g.Count
+		}
+		for j, agg := range a.Aggregates {
+			rows[i][agg.String()] = g.Values[j]
+		}
+	}
+	return render.Records(os.Stdout, f, columns, rows)
+}
 
-	customersPrintHelper(customers, tw)
-	tw.Flush()
+// formatValue formats a value for table and CSV output, with prices to two decimal places, times
+// in UTC and missing values empty.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', 2, 64)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
 }
 
-func printOrder(w io.Writer, orders ...*Order) {
-	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+// listFlags returns the paging and sorting flags of the show commands.
+func listFlags(sortFields []string) []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "the most records to show",
+		},
+		&cli.IntFlag{
+			Name:  "offset",
+			Usage: "the number of records to skip",
+		},
+		&cli.IntFlag{
+			Name:  "after",
+			Usage: "show the records after the one with this id, the last one of the previous page",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "sort by field[:desc], where field is one of " + strings.Join(sortFields, ", "),
+		},
+	}
+}
 
-	orderPrintHelper(orders, tw)
-	tw.Flush()
+// listOptions returns the paging and sorting options given by listFlags.
+func listOptions(cCtx *cli.Context, sortFields []string) (store.ListOptions, error) {
+	by, err := service.ParseSort(cCtx.String("sort"), sortFields)
+	if err != nil {
+		return store.ListOptions{}, err
+	}
+	return store.ListOptions{
+		Sort:   by,
+		Limit:  cCtx.Int("limit"),
+		Offset: cCtx.Int("offset"),
+		After:  cCtx.Int("after"),
+	}, nil
 }
 
-func printProduct(w io.Writer, products ...*Product) {
-	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+// renderDetails writes the detailed view of a get command in the format selected by --output,
+// calling table for table output.
+func renderDetails(cCtx *cli.Context, details any, table func()) error {
+	f, err := outputFormat(cCtx)
+	if err != nil {
+		return err
+	}
 
-	productPrintHelper(products, tw)
-	tw.Flush()
+	switch f {
+	case render.Table:
+		table()
+		return nil
+	case render.CSV:
+		return usageError(fmt.Sprintf("%s output is not supported by %s", f, cCtx.Command.Name))
+	default:
+		return render.Value(os.Stdout, f, details)
+	}
 }
 
-func newCreateCustomerCommand(db **sql.DB) *cli.Command {
+func newCreateCustomerCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
 		Name:      "create-customer",
 		Usage:     "Creates a new customer to go in the customers database, must specify email and state(2 letter code)",
 		ArgsUsage: "EMAIL STATE",
 		Action: func(cCtx *cli.Context) error {
 			if cCtx.NArg() < 2 {
-				return errors.New("Must specify email and state")
+				return usageError("Must specify email and state")
 			}
-			email := cCtx.Args().Get(0)
-			state := cCtx.Args().Get(1)
-			if len(state) != 2 {
-				return errors.New("State length must be 2")
-			}
-			_, ok := states[strings.ToUpper(state)]
-			if !ok {
-				return errors.New("State must be a valid U.S. State or Territory")
-			}
-
-			insertStatement := "INSERT INTO Customers (email, state) VALUES (?, ?)"
-			res, err := (*db).Exec(insertStatement, email, state)
-
+			c, err := (*svc).CreateCustomer(cCtx.Context, cCtx.Args().Get(0), cCtx.Args().Get(1))
 			if err != nil {
 				return err
 			}
-			customerID, err := res.LastInsertId()
-			if err != nil {
-				return err
-			}
-			printCustomer(os.Stdout, &Customer{int(customerID), email, state})
-
-			return nil
+			return renderRecords(cCtx, customerColumns, c)
 		},
 	}
 }
 
-func newCreateProductCommand(db **sql.DB) *cli.Command {
+func newCreateProductCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
 		Name:  "create-product",
 		Usage: "Creates a new product to go in the products database, must specify name and price",
@@ -345,390 +589,1167 @@ func newCreateProductCommand(db **sql.DB) *cli.Command {
 		},
 		Action: func(cCtx *cli.Context) error {
 			if cCtx.NArg() < 2 {
-				return errors.New("Must specify name and price")
+				return usageError("Must specify name and price")
 			}
 
-			name := cCtx.Args().Get(0)
-			price, err := strconv.ParseFloat(cCtx.Args().Get(1), 32)
-			price = (price * 100) / 100
+			price, err := service.ParsePrice(cCtx.Args().Get(1))
 			if err != nil {
 				return err
 			}
-			sku := cCtx.String("sku")
-
-			p := Product{
-				name:  name,
-				price: price,
-				sku:   sql.NullString{String: sku, Valid: true},
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
-			var insertStatement string
-			if sku != "" {
-				insertStatement = "INSERT INTO Products (name, price, sku) VALUES (?, ?, ?)"
-
-			} else {
-
-				insertStatement = "INSERT INTO Products (name, price) VALUES (?, ?)"
-
+			p, err := (*svc).CreateProduct(cCtx.Context, cCtx.Args().Get(0), price, cCtx.String("sku"))
+			if err != nil {
+				return err
 			}
-
-			return productsInsertHelper(*db, p, insertStatement, w)
-
+			return renderRecords(cCtx, productColumns, p)
 		},
 	}
 }
 
-func newCreateOrderCommand(db **sql.DB) *cli.Command {
+func newCreateOrderCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
 		Name:      "create-order",
 		Usage:     "Creates a new order to go in the order database, must specify customer_id and product_id",
 		ArgsUsage: "PRODUCT_ID CUSTOMER_ID",
 		Action: func(cCtx *cli.Context) error {
 			if cCtx.NArg() < 2 {
-				return errors.New("Must specify product_id and customer_id")
+				return usageError("Must specify product_id and customer_id")
 			}
-			pID, err := strconv.Atoi(cCtx.Args().Get(0))
+			pID, err := service.ParseID("product_id", cCtx.Args().Get(0))
 			if err != nil {
-				return errors.New("Must be valid integer")
+				return err
 			}
-			cID, err := strconv.Atoi(cCtx.Args().Get(1))
+			cID, err := service.ParseID("customer_id", cCtx.Args().Get(1))
 			if err != nil {
-				return errors.New("Must be valid integer")
+				return err
 			}
-			insertStatement := "INSERT INTO Orders (customer_id, product_id) VALUES (?, ?)"
-			res, err := (*db).Exec(insertStatement, cID, pID)
-			if err != nil {
-				row := (*db).QueryRow("SELECT * FROM Customers ORDER BY ID LIMIT 1")
-				var id int
-				var email string
-				var state string
 
-				err := row.Scan(&id, &email, &state)
-				if err != nil {
-					return errors.New("Customer ID does not exist")
-				}
-				log.Print(id)
-				if id < cID {
-					return errors.New("Customer ID does not exist")
-
-				}
-
-				return errors.New("Product ID does not exist")
-
-			}
-			orderID, err := res.LastInsertId()
+			o, err := (*svc).CreateOrder(cCtx.Context, cID, pID)
 			if err != nil {
 				return err
 			}
-			printOrder(os.Stdout, &Order{int(orderID), sql.NullString{String: "", Valid: false}, cID, pID})
-
-			return nil
+			return renderRecords(cCtx, orderColumns, o)
 		},
 	}
 }
 
-func newShowCustomerCommand(db **sql.DB, ctx context.Context) *cli.Command {
+// errNothingToUpdate is returned by the update commands when no field flag is set.
+const errNothingToUpdate = usageError("Must specify at least one field to update")
+
+// parseIDArg parses the ID argument of commands which act on a single record.
+func parseIDArg(cCtx *cli.Context, field string) (int, error) {
+	if cCtx.NArg() < 1 {
+		return 0, usageError("Must specify " + field)
+	}
+	return service.ParseID(field, cCtx.Args().Get(0))
+}
+
+func newUpdateCustomerCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
-		Name:  "show-customers",
-		Usage: "displays all the customers inside the customers database, optional flags to filter by email and state",
+		Name:      "update-customer",
+		Usage:     "Updates the email and/or state of a customer",
+		ArgsUsage: "CUSTOMER_ID",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "email",
-				Usage: "the email of the customer",
+				Usage: "the new email of the customer",
 			},
 			&cli.StringFlag{
 				Name:  "state",
-				Usage: "the state of the customer",
+				Usage: "the new state of the customer (2 letter code)",
 			},
 		},
 		Action: func(cCtx *cli.Context) error {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
-			statement := "SELECT * FROM Customers WHERE Email LIKE CONCAT('%', ?, '%') AND State LIKE CONCAT('%', ?, '%')"
-			email := cCtx.String("email")
-			state := cCtx.String("state")
-			err := customerHelper(w, *db, statement, email, state, ctx)
+			id, err := parseIDArg(cCtx, "customer_id")
 			if err != nil {
 				return err
 			}
-			return nil
+
+			var u service.CustomerUpdate
+			if cCtx.IsSet("email") {
+				email := cCtx.String("email")
+				u.Email = &email
+			}
+			if cCtx.IsSet("state") {
+				state := cCtx.String("state")
+				u.State = &state
+			}
+			if u == (service.CustomerUpdate{}) {
+				return errNothingToUpdate
+			}
+
+			c, err := (*svc).UpdateCustomer(cCtx.Context, id, u)
+			if err != nil {
+				return err
+			}
+			return renderRecords(cCtx, customerColumns, c)
 		},
 	}
 }
 
-func newShowProductCommand(db **sql.DB, ctx context.Context) *cli.Command {
+func newUpdateProductCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
-		Name:  "show-products",
-		Usage: "Shows the products from the products database, optional flag name to filter by name",
+		Name:      "update-product",
+		Usage:     "Updates the name, price and/or sku of a product, an empty sku removes it",
+		ArgsUsage: "PRODUCT_ID",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "name",
-				Usage: "the name of the product",
+				Usage: "the new name of the product",
+			},
+			&cli.StringFlag{
+				Name:  "price",
+				Usage: "the new price of the product",
+			},
+			&cli.StringFlag{
+				Name:  "sku",
+				Usage: "the new sku of the product",
 			},
 		},
 		Action: func(cCtx *cli.Context) error {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
-			statement := "SELECT * FROM PRODUCTS WHERE Name LIKE CONCAT('%', ?, '%')"
-			name := cCtx.String("name")
-			productHelper(w, *db, statement, name, ctx)
-			return nil
+			id, err := parseIDArg(cCtx, "product_id")
+			if err != nil {
+				return err
+			}
+
+			var u service.ProductUpdate
+			if cCtx.IsSet("name") {
+				name := cCtx.String("name")
+				u.Name = &name
+			}
+			if cCtx.IsSet("price") {
+				price, err := service.ParsePrice(cCtx.String("price"))
+				if err != nil {
+					return err
+				}
+				u.Price = &price
+			}
+			if cCtx.IsSet("sku") {
+				sku := cCtx.String("sku")
+				u.Sku = &sku
+			}
+			if u == (service.ProductUpdate{}) {
+				return errNothingToUpdate
+			}
+
+			p, err := (*svc).UpdateProduct(cCtx.Context, id, u)
+			if err != nil {
+				return err
+			}
+			return renderRecords(cCtx, productColumns, p)
 		},
 	}
 }
 
-func newShowOrderCommand(db **sql.DB, ctx context.Context) *cli.Command {
+func newUpdateOrderCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
-		Name:  "show-orders",
-		Usage: "displays all the orders within the orders database with an optional customerId and productId filter",
+		Name:      "update-order",
+		Usage:     "Updates the customer and/or product of an order",
+		ArgsUsage: "ORDER_ID",
 		Flags: []cli.Flag{
-			&cli.IntFlag{
+			&cli.StringFlag{
 				Name:  "customer-id",
-				Usage: "the customer-id of the customer",
+				Usage: "the id of the new customer of the order",
 			},
-			&cli.IntFlag{
+			&cli.StringFlag{
 				Name:  "product-id",
-				Usage: "the product-id of the product",
+				Usage: "the id of the new product of the order",
 			},
-		}, Action: func(cCtx *cli.Context) error {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
-			args := []any{}
-			cID := cCtx.Int("customer-id")
-			pID := cCtx.Int("product-id")
-			var statement string
-			if cCtx.Int("customer-id") != 0 && cCtx.Int("product-id") != 0 {
-
-				statement = "SELECT * FROM ORDERS WHERE customer_id=? AND product_id=?"
-				args = append(args, cID, pID)
-
-			} else if cCtx.Int("customer-id") != 0 {
-
-				statement = "SELECT * FROM ORDERS WHERE customer_id =?"
-				args = append(args, cID)
-
-			} else if cCtx.Int("product-id") != 0 {
-
-				statement = "SELECT * FROM ORDERS WHERE product_id =?"
-				args = append(args, pID)
-
-			} else {
-
-				statement = "SELECT * FROM ORDERS "
+		},
+		Action: func(cCtx *cli.Context) error {
+			id, err := parseIDArg(cCtx, "order_id")
+			if err != nil {
+				return err
+			}
 
+			var u service.OrderUpdate
+			if cCtx.IsSet("customer-id") {
+				cID, err := service.ParseID("customer_id", cCtx.String("customer-id"))
+				if err != nil {
+					return err
+				}
+				u.CustomerID = &cID
+			}
+			if cCtx.IsSet("product-id") {
+				pID, err := service.ParseID("product_id", cCtx.String("product-id"))
+				if err != nil {
+					return err
+				}
+				u.ProductID = &pID
 			}
-			err := ordersHelper(w, *db, statement, args, ctx)
+			if u == (service.OrderUpdate{}) {
+				return errNothingToUpdate
+			}
+
+			o, err := (*svc).UpdateOrder(cCtx.Context, id, u)
 			if err != nil {
 				return err
 			}
-			return nil
+			return renderRecords(cCtx, orderColumns, o)
 		},
 	}
 }
-func runMigrations(ctx context.Context) *cli.Command {
+
+// errDeleteAborted is returned by the delete commands when the user does not confirm.
+var errDeleteAborted = errors.New("delete aborted, re-run with --yes to delete without confirming")
+
+func deleteFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "delete without asking for confirmation",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only show what would be deleted",
+		},
+	}
+}
+
+// confirmDelete prints what a delete command is about to do, and returns errDeleteAborted unless
+// the user confirms it or passed --yes. done is true if the command must stop without deleting,
+// on --dry-run.
+func confirmDelete(cCtx *cli.Context, description string) (done bool, err error) {
+	// Keep machine readable output free of the prompt.
+	w := cCtx.App.Writer
+	if f, _ := outputFormat(cCtx); f != render.Table {
+		w = cCtx.App.ErrWriter
+	}
+	fmt.Fprintln(w, description)
+
+	if cCtx.Bool("dry-run") {
+		fmt.Fprintln(w, "dry run, nothing was deleted")
+		return true, nil
+	}
+	if cCtx.Bool("yes") {
+		return false, nil
+	}
+
+	fmt.Fprint(w, "Continue? [y/N] ")
+	answer, err := bufio.NewReader(cCtx.App.Reader).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return false, nil
+	default:
+		return false, errDeleteAborted
+	}
+}
+
+func newDeleteCustomerCommand(svc **service.Service) *cli.Command {
 	return &cli.Command{
-		Name:  "run-migrations",
-		Usage: "runs migrations for all orgs",
+		Name:      "delete-customer",
+		Usage:     "Deletes a customer along with all of their orders",
+		ArgsUsage: "CUSTOMER_ID",
+		Flags:     deleteFlags(),
 		Action: func(cCtx *cli.Context) error {
-			path := "migrations"
-			runner := NewMigrationRunner(path)
-
-			state, err := migration.LoadMigrationState(ctx, migration.DefaultMigrationStatePath)
+			id, err := parseIDArg(cCtx, "customer_id")
 			if err != nil {
 				return err
 			}
 
-			state, err = runner.RunAll(ctx, state)
+			c, orders, err := (*svc).PreviewDeleteCustomer(cCtx.Context, id)
 			if err != nil {
 				return err
 			}
+			if err := renderRecords(cCtx, customerColumns, c); err != nil {
+				return err
+			}
 
-			return migration.SaveMigrationState(ctx, state, migration.DefaultMigrationStatePath)
+			done, err := confirmDelete(cCtx, fmt.Sprintf("Deleting customer %d also deletes %d order(s).", id, orders))
+			if done || err != nil {
+				return err
+			}
+			return (*svc).DeleteCustomer(cCtx.Context, id)
 		},
 	}
+}
 
+func newDeleteProductCommand(svc **service.Service) *cli.Command {
+	return &cli.Command{
+		Name:      "delete-product",
+		Usage:     "Deletes a product along with all of its orders",
+		ArgsUsage: "PRODUCT_ID",
+		Flags:     deleteFlags(),
+		Action: func(cCtx *cli.Context) error {
+			id, err := parseIDArg(cCtx, "product_id")
+			if err != nil {
+				return err
+			}
+
+			p, orders, err := (*svc).PreviewDeleteProduct(cCtx.Context, id)
+			if err != nil {
+				return err
+			}
+			if err := renderRecords(cCtx, productColumns, p); err != nil {
+				return err
+			}
+
+			done, err := confirmDelete(cCtx, fmt.Sprintf("Deleting product %d also deletes %d order(s).", id, orders))
+			if done || err != nil {
+				return err
+			}
+			return (*svc).DeleteProduct(cCtx.Context, id)
+		},
+	}
 }
 
-func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
+func newDeleteOrderCommand(svc **service.Service) *cli.Command {
+	return &cli.Command{
+		Name:      "delete-order",
+		Usage:     "Deletes an order",
+		ArgsUsage: "ORDER_ID",
+		Flags:     deleteFlags(),
+		Action: func(cCtx *cli.Context) error {
+			id, err := parseIDArg(cCtx, "order_id")
+			if err != nil {
+				return err
+			}
 
-	var db *sql.DB
-	defer func() {
-		if db != nil {
-			db.Close()
-		}
-	}()
+			o, err := (*svc).GetOrder(cCtx.Context, id)
+			if err != nil {
+				return err
+			}
+			if err := renderRecords(cCtx, orderColumns, o); err != nil {
+				return err
+			}
 
-	app := &cli.App{
-		Name: "store",
+			done, err := confirmDelete(cCtx, fmt.Sprintf("Deleting order %d.", id))
+			if done || err != nil {
+				return err
+			}
+			return (*svc).DeleteOrder(cCtx.Context, id)
+		},
+	}
+}
+
+// lookupArg returns the ID argument of a get command, or ok false if the record is looked up by
+// flag instead. Exactly one of the two must be given.
+func lookupArg(cCtx *cli.Context, field, flag string) (id int, ok bool, err error) {
+	switch {
+	case cCtx.NArg() > 0 && cCtx.IsSet(flag):
+		return 0, false, usageError("Must specify either " + field + " or --" + flag + ", not both")
+	case cCtx.IsSet(flag):
+		return 0, false, nil
+	}
+	id, err = parseIDArg(cCtx, field)
+	return id, err == nil, err
+}
+
+func newGetCustomerCommand(svc **service.Service) *cli.Command {
+	return &cli.Command{
+		Name:      "get-customer",
+		Usage:     "Shows a single customer and their orders, looked up by id or email",
+		ArgsUsage: "[CUSTOMER_ID]",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:  "org",
-				Usage: "org to connect to",
-				Value: "default",
+				Name:  "email",
+				Usage: "look the customer up by their exact email instead",
 			},
 		},
-		Before: func(cCtx *cli.Context) error {
-			org := cCtx.String("org")
-			log.Println("connecting to org:", org)
+		Action: func(cCtx *cli.Context) error {
+			id, byID, err := lookupArg(cCtx, "customer_id", "email")
+			if err != nil {
+				return err
+			}
 
-			orgDB, err := connectDB(org)
+			var c *domain.Customer
+			if byID {
+				c, err = (*svc).GetCustomer(cCtx.Context, id)
+			} else {
+				c, err = (*svc).GetCustomerByEmail(cCtx.Context, cCtx.String("email"))
+			}
 			if err != nil {
 				return err
 			}
 
-			db = orgDB
-			return nil
+			details, err := (*svc).CustomerDetails(cCtx.Context, c)
+			if err != nil {
+				return err
+			}
+			return renderDetails(cCtx, details, func() {
+				printCustomer(os.Stdout, details.Customer)
+				fmt.Fprintln(os.Stdout, "\nOrders:")
+				printOrder(os.Stdout, details.Orders...)
+			})
 		},
-		Commands: []*cli.Command{
-			runMigrations(ctx),
-			newCreateCustomerCommand(&db),
-			newCreateProductCommand(&db),
-			newCreateOrderCommand(&db),
-			newShowCustomerCommand(&db, ctx),
-			newShowProductCommand(&db, ctx),
-			newShowOrderCommand(&db, ctx),
+	}
+}
+
+func newGetProductCommand(svc **service.Service) *cli.Command {
+	return &cli.Command{
+		Name:      "get-product",
+		Usage:     "Shows a single product and its orders, looked up by id or sku",
+		ArgsUsage: "[PRODUCT_ID]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "sku",
+				Usage: "look the product up by its exact sku instead",
+			},
+		},
+		Action: func(cCtx *cli.Context) error {
+			id, byID, err := lookupArg(cCtx, "product_id", "sku")
+			if err != nil {
+				return err
+			}
+
+			var p *domain.Product
+			if byID {
+				p, err = (*svc).GetProduct(cCtx.Context, id)
+			} else {
+				p, err = (*svc).GetProductBySku(cCtx.Context, cCtx.String("sku"))
+			}
+			if err != nil {
+				return err
+			}
+
+			details, err := (*svc).ProductDetails(cCtx.Context, p)
+			if err != nil {
+				return err
+			}
+			return renderDetails(cCtx, details, func() {
+				printProduct(os.Stdout, details.Product)
+				fmt.Fprintln(os.Stdout, "\nOrders:")
+				printOrder(os.Stdout, details.Orders...)
+			})
 		},
 	}
+}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+func newGetOrderCommand(svc **service.Service) *cli.Command {
+	return &cli.Command{
+		Name:      "get-order",
+		Usage:     "Shows a single order with its customer and product",
+		ArgsUsage: "ORDER_ID",
+		Action: func(cCtx *cli.Context) error {
+			id, err := parseIDArg(cCtx, "order_id")
+			if err != nil {
+				return err
+			}
+
+			details, err := (*svc).OrderDetails(cCtx.Context, id)
+			if err != nil {
+				return err
+			}
+			return renderDetails(cCtx, details, func() {
+				printOrder(os.Stdout, details.Order)
+				fmt.Fprintln(os.Stdout, "\nCustomer:")
+				printCustomer(os.Stdout, details.Customer)
+				fmt.Fprintln(os.Stdout, "\nProduct:")
+				printProduct(os.Stdout, details.Product)
+			})
+		},
 	}
+}
 
+func newShowCustomerCommand(svc **service.Service, ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:  "show-customers",
+		Usage: "displays all the customers inside the customers database, optional flags to filter by id, email and state",
+		Flags: append([]cli.Flag{
+			&cli.IntSliceFlag{
+				Name:  "id",
+				Usage: "the ids of the customers, repeated or comma separated",
+			},
+			&cli.StringFlag{
+				Name:  "email",
+				Usage: "the email of the customer",
+			},
+			matchFlag("email"),
+			&cli.StringFlag{
+				Name:  "state",
+				Usage: "the state of the customer",
+			},
+			matchFlag("state"),
+			&cli.StringSliceFlag{
+				Name:  "states",
+				Usage: "the exact states of the customers, repeated or comma separated",
+			},
+			whereFlag("state in (WA, CA) and email != 'a@b.io'"),
+		}, append(aggregateFlags(store.CustomerFields), listFlags(store.CustomerSortFields)...)...),
+		Action: func(cCtx *cli.Context) error {
+			opts, err := listOptions(cCtx, store.CustomerSortFields)
+			if err != nil {
+				return err
+			}
+			email, err := matchArg(cCtx, "email")
+			if err != nil {
+				return err
+			}
+			state, err := matchArg(cCtx, "state")
+			if err != nil {
+				return err
+			}
+			where, err := whereArg(cCtx, store.CustomerFields)
+			if err != nil {
+				return err
+			}
+			f := store.CustomerFilter{
+				IDs:    cCtx.IntSlice("id"),
+				Email:  email,
+				State:  state,
+				States: cCtx.StringSlice("states"),
+				Where:  where,
+			}
+			a, aggregate, err := aggregationArg(cCtx, store.CustomerFields)
+			if err != nil {
+				return err
+			}
+			if aggregate {
+				groups, err := (*svc).AggregateCustomers(ctx, f, a)
+				if err != nil {
+					return err
+				}
+				return renderGroups(cCtx, a, groups)
+			}
+			customers, err := (*svc).ListCustomers(ctx, f, opts)
+			if err != nil {
+				return err
+			}
+			return renderPage(cCtx, customerColumns, customers, "customers")
+		},
+	}
 }
 
-func orderPrintHelper(orders []*Order, w *tabwriter.Writer) error {
-	fmt.Fprintf(w, "%-*s\t%-*s\t%-*s\t\n", 10, "OrderID", 12, "ProductID", 13, "CustomerID")
-	for _, o := range orders {
-		o.Print(w)
+func newShowProductCommand(svc **service.Service, ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:  "show-products",
+		Usage: "Shows the products from the products database, optional flags to filter by id, name, price and sku",
+		Flags: append([]cli.Flag{
+			&cli.IntSliceFlag{
+				Name:  "id",
+				Usage: "the ids of the products, repeated or comma separated",
+			},
+			&cli.StringFlag{
+				Name:  "name",
+				Usage: "the name of the product",
+			},
+			matchFlag("name"),
+			&cli.Float64Flag{
+				Name:  "min-price",
+				Usage: "the lowest price of the products",
+			},
+			&cli.Float64Flag{
+				Name:  "max-price",
+				Usage: "the highest price of the products",
+			},
+			&cli.BoolFlag{
+				Name:  "has-sku",
+				Usage: "only show products with a sku",
+			},
+			&cli.BoolFlag{
+				Name:  "no-sku",
+				Usage: "only show products without a sku",
+			},
+			whereFlag("price > 10 and sku is not null"),
+		}, append(aggregateFlags(store.ProductFields), listFlags(store.ProductSortFields)...)...),
+		Action: func(cCtx *cli.Context) error {
+			opts, err := listOptions(cCtx, store.ProductSortFields)
+			if err != nil {
+				return err
+			}
+			name, err := matchArg(cCtx, "name")
+			if err != nil {
+				return err
+			}
+			hasSku, err := hasSkuArg(cCtx)
+			if err != nil {
+				return err
+			}
+			where, err := whereArg(cCtx, store.ProductFields)
+			if err != nil {
+				return err
+			}
+			f := store.ProductFilter{
+				IDs:      cCtx.IntSlice("id"),
+				Name:     name,
+				MinPrice: float64Arg(cCtx, "min-price"),
+				MaxPrice: float64Arg(cCtx, "max-price"),
+				HasSku:   hasSku,
+				Where:    where,
+			}
+			a, aggregate, err := aggregationArg(cCtx, store.ProductFields)
+			if err != nil {
+				return err
+			}
+			if aggregate {
+				groups, err := (*svc).AggregateProducts(ctx, f, a)
+				if err != nil {
+					return err
+				}
+				return renderGroups(cCtx, a, groups)
+			}
+			products, err := (*svc).ListProducts(ctx, f, opts)
+			if err != nil {
+				return err
+			}
+			return renderPage(cCtx, productColumns, products, "products")
+		},
 	}
-	return nil
 }
 
-func ordersHelper(w *tabwriter.Writer, db *sql.DB, statement string, args []any, ctx context.Context) error {
-	var rows *sql.Rows
-	var err error
-	if len(args) == 1 {
-		rows, err = db.QueryContext(ctx, statement, args[0])
-	} else if len(args) == 2 {
-		rows, err = db.QueryContext(ctx, statement, args[0], args[1])
-	} else {
-		rows, err = db.QueryContext(ctx, statement)
+// float64Arg returns the value of the flag called name, or nil if it is not set.
+func float64Arg(cCtx *cli.Context, name string) *float64 {
+	if !cCtx.IsSet(name) {
+		return nil
 	}
-	if err != nil {
-		return err
+	v := cCtx.Float64(name)
+	return &v
+}
+
+// hasSkuArg returns the sku filter given by --has-sku or --no-sku, or nil if neither is set.
+func hasSkuArg(cCtx *cli.Context) (*bool, error) {
+	hasSku, noSku := cCtx.Bool("has-sku"), cCtx.Bool("no-sku")
+	switch {
+	case hasSku && noSku:
+		return nil, usageError("Must specify either --has-sku or --no-sku, not both")
+	case hasSku || noSku:
+		return &hasSku, nil
 	}
-	defer rows.Close()
-	orders := []*Order{}
-	for rows.Next() {
-		o, err := NewOrder(rows)
-		if err != nil {
-			return err
-		}
-		orders = append(orders, o)
+	return nil, nil
+}
+
+// dateArg returns the date given by the flag called name, or the zero time if it is not set.
+// The end of a range includes the whole of its last day.
+func dateArg(cCtx *cli.Context, name string, end bool) (time.Time, error) {
+	if cCtx.String(name) == "" {
+		return time.Time{}, nil
 	}
-	if rows.Err() != nil {
-		return err
+	return service.ParseDate(strings.ReplaceAll(name, "-", "_"), cCtx.String(name), end)
+}
+
+func newShowOrderCommand(svc **service.Service, ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:  "show-orders",
+		Usage: "displays all the orders within the orders database with optional filters by id, customer, product and creation date",
+		Flags: append([]cli.Flag{
+			&cli.IntSliceFlag{
+				Name:  "id",
+				Usage: "the ids of the orders, repeated or comma separated",
+			},
+			&cli.IntSliceFlag{
+				Name:  "customer-id",
+				Usage: "the customer-id of the customer, repeated or comma separated",
+			},
+			&cli.IntSliceFlag{
+				Name:  "product-id",
+				Usage: "the product-id of the product, repeated or comma separated",
+			},
+			&cli.StringFlag{
+				Name:  "created-from",
+				Usage: "the first date or time the orders were created, such as 2023-06-01",
+			},
+			&cli.StringFlag{
+				Name:  "created-to",
+				Usage: "the last date or time the orders were created, including the whole of a date",
+			},
+			whereFlag("product.price > 10 and customer.state in (WA, CA)"),
+			&cli.BoolFlag{
+				Name:  "expand",
+				Usage: "show the creation time of each order with the details of its customer and product",
+			},
+		}, append(aggregateFlags(store.OrderFields), listFlags(store.OrderSortFields)...)...),
+		Action: func(cCtx *cli.Context) error {
+			opts, err := listOptions(cCtx, store.OrderSortFields)
+			if err != nil {
+				return err
+			}
+			from, err := dateArg(cCtx, "created-from", false)
+			if err != nil {
+				return err
+			}
+			before, err := dateArg(cCtx, "created-to", true)
+			if err != nil {
+				return err
+			}
+			where, err := whereArg(cCtx, store.OrderFields)
+			if err != nil {
+				return err
+			}
+			f := store.OrderFilter{
+				IDs:           cCtx.IntSlice("id"),
+				CustomerIDs:   cCtx.IntSlice("customer-id"),
+				ProductIDs:    cCtx.IntSlice("product-id"),
+				CreatedFrom:   from,
+				CreatedBefore: before,
+				Where:         where,
+			}
+			a, aggregate, err := aggregationArg(cCtx, store.OrderFields)
+			if err != nil {
+				return err
+			}
+			if aggregate && cCtx.Bool("expand") {
+				return usageError("Must not specify --expand with --count, --group-by or an aggregate")
+			}
+			if aggregate {
+				groups, err := (*svc).AggregateOrders(ctx, f, a)
+				if err != nil {
+					return err
+				}
+				return renderGroups(cCtx, a, groups)
+			}
+			if cCtx.Bool("expand") {
+				orders, err := (*svc).ListOrderDetails(ctx, f, opts)
+				if err != nil {
+					return err
+				}
+				return renderPage(cCtx, orderDetailsColumns, orders, "orders")
+			}
+			orders, err := (*svc).ListOrders(ctx, f, opts)
+			if err != nil {
+				return err
+			}
+			return renderPage(cCtx, orderColumns, orders, "orders")
+		},
 	}
-	err = orderPrintHelper(orders, w)
-	if err != nil {
-		return err
+}
+
+func runMigrations(ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:  "run-migrations",
+		Usage: "runs migrations for all orgs",
+		Action: func(cCtx *cli.Context) error {
+			runner := NewMigrationRunner(migrationsPath)
+
+			state, err := migration.LoadMigrationState(ctx, migration.DefaultMigrationStatePath)
+			if err != nil {
+				return err
+			}
+
+			state, runErr := runner.RunAll(ctx, state)
+			if err := migration.SaveMigrationState(ctx, state, migration.DefaultMigrationStatePath); err != nil {
+				return err
+			}
+
+			return runErr
+		},
 	}
-	w.Flush()
 
-	return nil
 }
 
-func productPrintHelper(products []*Product, w *tabwriter.Writer) error {
-	fmt.Fprintf(w, "%-*s\t%-*s\t%-*s\t%-*s\t\n", 3, "ID", 25, "Name", 13, "Price", 25, "Sku")
+func newCreateOrgCommand(ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:      "create-org",
+		Usage:     "creates the database for a new org, runs all migrations against it and registers it in the migration state",
+		ArgsUsage: "NAME",
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() < 1 {
+				return usageError("Must specify org name")
+			}
+			name := cCtx.Args().Get(0)
+
+			state, err := migration.LoadMigrationState(ctx, migration.DefaultMigrationStatePath)
+			if err != nil {
+				return err
+			}
+
+			o, err := createOrg(ctx, NewMigrationRunner(migrationsPath), state, name)
+			if err != nil {
+				return err
+			}
 
-	for _, p := range products {
-		p.Print(w)
+			log.Printf("created org %s at migration %d", o.Name, o.LastRanMigrationID)
+			return nil
+		},
 	}
-	return nil
 }
 
-func productHelper(w *tabwriter.Writer, db *sql.DB, statement string, name string, ctx context.Context) error {
-	rows, err := db.QueryContext(ctx, statement, name)
+// createOrg creates the database for name, migrates it and saves it into state. If any step
+// fails the database is dropped again and state is left untouched.
+func createOrg(ctx context.Context, runner *MigrationRunner, state *migration.MigrationState, name string) (o *migration.OrgMigrationState, err error) {
+	if err := org.ValidateName(name); err != nil {
+		return nil, err
+	}
+	for _, o := range state.Orgs {
+		if o.Name == name {
+			return nil, fmt.Errorf("org %s already exists", name)
+		}
+	}
+
+	conn, err := catalog.Connection(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer rows.Close()
 
-	products := []*Product{}
-	for rows.Next() {
-		p, err := NewProduct(rows)
-		if err != nil {
-			return err
+	if err := database.Create(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to create database for org %s: %w", name, err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if dropErr := database.Drop(ctx, conn); dropErr != nil {
+			log.Printf("failed to roll back database for org %s: %v", name, dropErr)
 		}
-		products = append(products, p)
+	}()
+
+	o = &migration.OrgMigrationState{Name: name, LastRanMigrationID: -1}
+	if err := runner.runOrg(ctx, o); err != nil {
+		return nil, fmt.Errorf("failed to migrate org %s: %w", name, err)
 	}
-	if rows.Err() != nil {
-		return err
+
+	state.Orgs = append(state.Orgs, o)
+	if err := migration.SaveMigrationState(ctx, state, migration.DefaultMigrationStatePath); err != nil {
+		state.Orgs = state.Orgs[:len(state.Orgs)-1]
+		return nil, err
 	}
 
-	err = productPrintHelper(products, w)
-	if err != nil {
-		return err
+	if err := audit.Append(audit.DefaultAuditLogPath, audit.Record{
+		Action: "create-org",
+		Org:    name,
+		Actor:  audit.Actor(),
+		At:     time.Now().UTC(),
+	}); err != nil {
+		log.Printf("failed to write audit record: %v", err)
 	}
-	w.Flush()
-	return nil
 
+	return o, nil
 }
 
-func customerHelper(w *tabwriter.Writer, db *sql.DB, statement string, email string, state string, ctx context.Context) error {
-	rows, err := db.QueryContext(ctx, statement, email, state)
-	if err != nil {
-		return err
+// orgConfirmationToken is the token which must be passed to delete-org to confirm that name
+// really is the org to delete.
+func orgConfirmationToken(name string) string {
+	sum := sha256.Sum256([]byte("delete-org:" + name))
+	return hex.EncodeToString(sum[:4])
+}
+
+func newDeleteOrgCommand(ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:      "delete-org",
+		Usage:     "archives an org's data, drops its database and removes it from the migration state",
+		ArgsUsage: "NAME",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "confirm",
+				Usage: "the confirmation token for the org, printed when running without it",
+			},
+			&cli.StringFlag{
+				Name:  "archive-dir",
+				Usage: "the directory the org's archive is written to",
+				Value: "archives",
+			},
+		},
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() < 1 {
+				return usageError("Must specify org name")
+			}
+			name := cCtx.Args().Get(0)
+
+			token := orgConfirmationToken(name)
+			if cCtx.String("confirm") != token {
+				return usageError(fmt.Sprintf("deleting org %s drops all of its data, re-run with --confirm %s to continue", name, token))
+			}
+
+			state, err := migration.LoadMigrationState(ctx, migration.DefaultMigrationStatePath)
+			if err != nil {
+				return err
+			}
+
+			path, err := deleteOrg(ctx, state, name, cCtx.String("archive-dir"))
+			if err != nil {
+				return err
+			}
+
+			log.Printf("deleted org %s, data archived to %s", name, path)
+			return nil
+		},
 	}
-	defer rows.Close()
+}
 
-	customers := []*Customer{}
-	for rows.Next() {
-		c, err := NewCustomer(rows)
-		if err != nil {
-			return err
+// deleteOrg archives the org's data into archiveDir, removes it from state and drops its database.
+// Nothing is dropped unless the archive was written and the state saved successfully, and a failed
+// drop puts the org back into state so that the deletion can be retried.
+func deleteOrg(ctx context.Context, state *migration.MigrationState, name, archiveDir string) (string, error) {
+	if name == org.Default {
+		return "", usageError(fmt.Sprintf("the %s org cannot be deleted", org.Default))
+	}
+	idx := -1
+	for i, o := range state.Orgs {
+		if o.Name == name {
+			idx = i
 		}
-		customers = append(customers, c)
 	}
-	if rows.Err() != nil {
-		return err
+	if idx == -1 {
+		return "", fmt.Errorf("org %s does not exist", name)
 	}
 
-	err = customersPrintHelper(customers, w)
+	db, err := connectDB(ctx, name)
 	if err != nil {
-		return err
+		return "", err
 	}
-	w.Flush()
-	return nil
-}
-
-func customersPrintHelper(customers []*Customer, w *tabwriter.Writer) error {
-	fmt.Fprintf(w, "%-*s\t%-*s\t%-*s\t\n", 3, "ID", 50, "Email", 2, "State")
-	for _, c := range customers {
-		c.Print(w)
+	a, err := archive.Export(ctx, db, name, archive.Tables...)
+	db.Close()
+	if err != nil {
+		return "", err
 	}
-	return nil
-}
 
-func productsInsertHelper(db *sql.DB, p Product, statement string, w *tabwriter.Writer) error {
-	var res sql.Result
-	var err error
-	if p.sku.String != "" {
-		res, err = db.Exec(statement, p.name, p.price, p.sku)
-	} else {
-		res, err = db.Exec(statement, p.name, p.price)
+	if err := os.MkdirAll(archiveDir, 0700); err != nil {
+		return "", err
 	}
+	path := filepath.Join(archiveDir, fmt.Sprintf("%s-%s.json", name, a.ExportedAt.Format("20060102T150405Z")))
+	if err := a.Save(path); err != nil {
+		return "", err
+	}
+
+	conn, err := catalog.Connection(name)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	orgs := state.Orgs
+	state.Orgs = append(append([]*migration.OrgMigrationState{}, orgs[:idx]...), orgs[idx+1:]...)
+	if err := migration.SaveMigrationState(ctx, state, migration.DefaultMigrationStatePath); err != nil {
+		state.Orgs = orgs
+		return "", err
 	}
 
-	productId, err := res.LastInsertId()
+	if err := database.Drop(ctx, conn); err != nil {
+		state.Orgs = orgs
+		if saveErr := migration.SaveMigrationState(ctx, state, migration.DefaultMigrationStatePath); saveErr != nil {
+			log.Printf("failed to restore org %s in the migration state: %v", name, saveErr)
+		}
+		return "", fmt.Errorf("failed to drop database for org %s: %w", name, err)
+	}
+
+	if err := audit.Append(audit.DefaultAuditLogPath, audit.Record{
+		Action:  "delete-org",
+		Org:     name,
+		Actor:   audit.Actor(),
+		At:      time.Now().UTC(),
+		Details: map[string]string{"archive": path},
+	}); err != nil {
+		// The org is already gone, so this must not turn the deletion into a failure.
+		log.Printf("failed to write audit record: %v", err)
+	}
+
+	return path, nil
+}
+
+// catalog resolves the connection settings of each org. It is replaced by one built from the
+// loaded configuration before any command runs.
+var catalog = org.NewCatalog(config.Default(), os.LookupEnv)
+
+// loadConfig layers the command line flags over the config file and environment. Passwords can
+// only be passed as a file on the command line, so they never show up in the process list.
+func loadConfig(cCtx *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(cCtx.String("config"), cCtx.IsSet("config"), os.LookupEnv)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	printProduct(os.Stdout, &Product{int(productId), p.name, p.price, p.sku})
+	if cCtx.IsSet("db-driver") {
+		cfg.Database.Driver = cCtx.String("db-driver")
+	}
+	if cCtx.IsSet("db-path") {
+		cfg.Database.Path = cCtx.String("db-path")
+	}
+	if cCtx.IsSet("db-user") {
+		cfg.Database.User = cCtx.String("db-user")
+	}
+	if cCtx.IsSet("db-password-file") {
+		cfg.Database.SetPasswordFile(cCtx.String("db-password-file"))
+	}
+	if cCtx.IsSet("db-host") {
+		cfg.Database.Host = cCtx.String("db-host")
+	}
+	if cCtx.IsSet("db-port") {
+		cfg.Database.Port = cCtx.Int("db-port")
+	}
 
-	return nil
+	if err := cfg.Database.ResolvePassword(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Process exit codes, one per class of error.
+const (
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitUnavailable = 4
+)
+
+// usageError is returned when a command is run with missing arguments.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// exitCode returns the process exit code for err.
+func exitCode(err error) int {
+	var (
+		usageErr      usageError
+		validationErr *domain.ValidationError
+		netErr        net.Error
+	)
+	switch {
+	case errors.As(err, &usageErr), errors.As(err, &validationErr), errors.Is(err, org.ErrInvalidName):
+		return exitUsage
+	case errors.Is(err, domain.ErrCustomerNotFound),
+		errors.Is(err, domain.ErrProductNotFound),
+		errors.Is(err, domain.ErrOrderNotFound),
+		errors.Is(err, org.ErrUnknownOrg),
+		errors.Is(err, database.ErrDatabaseNotFound):
+		return exitNotFound
+	case errors.Is(err, database.ErrTLSHandshake), errors.Is(err, database.ErrNoReplicaAvailable), errors.As(err, &netErr):
+		return exitUnavailable
+	default:
+		return exitError
+	}
+}
+
+var orgManagementCommands = map[string]bool{
+	"run-migrations": true,
+	"create-org":     true,
+	"delete-org":     true,
+}
+
+// readOnlyCommands are served by a read replica when the org has one.
+var readOnlyCommands = map[string]bool{
+	"show-customers": true,
+	"show-products":  true,
+	"show-orders":    true,
+	"get-customer":   true,
+	"get-product":    true,
+	"get-order":      true,
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run runs the command given by os.Args and returns the process's exit code. It is separate from
+// main so that its deferred clean up runs before the process exits.
+func run() int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var db *sql.DB
+	var svc *service.Service
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
 
+	app := &cli.App{
+		Name: "store",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "org",
+				Usage: "org to connect to",
+				Value: org.Default,
+			},
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "path to the config file",
+				Value:   config.DefaultConfigPath,
+				EnvVars: []string{config.EnvConfig},
+			},
+			newOutputFlag(),
+			&cli.StringFlag{
+				Name:  "db-driver",
+				Usage: "the database driver, mysql, sqlite or postgres, overrides " + config.EnvDBDriver + " and the config file",
+			},
+			&cli.StringFlag{
+				Name:  "db-path",
+				Usage: "the directory holding sqlite databases, overrides " + config.EnvDBPath + " and the config file",
+			},
+			&cli.StringFlag{
+				Name:  "db-user",
+				Usage: "the database user, overrides " + config.EnvDBUser + " and the config file",
+			},
+			&cli.StringFlag{
+				Name:  "db-password-file",
+				Usage: "a file containing the database password, overrides " + config.EnvDBPassword + ", " + config.EnvDBPasswordFile + " and the config file",
+			},
+			&cli.StringFlag{
+				Name:  "db-host",
+				Usage: "the database host, overrides " + config.EnvDBHost + " and the config file",
+			},
+			&cli.IntFlag{
+				Name:  "db-port",
+				Usage: "the database port, overrides " + config.EnvDBPort + " and the config file",
+			},
+		},
+		Before: func(cCtx *cli.Context) error {
+			cfg, err := loadConfig(cCtx)
+			if err != nil {
+				return err
+			}
+			catalog = org.NewCatalog(cfg, os.LookupEnv)
+
+			// org management commands open their own connections per org.
+			if orgManagementCommands[cCtx.Args().First()] {
+				return nil
+			}
+
+			name := cCtx.String("org")
+			state, err := migration.LoadMigrationState(ctx, migration.DefaultMigrationStatePath)
+			if err != nil {
+				return err
+			}
+			if err := org.NewRegistry(state).Lookup(name); err != nil {
+				return err
+			}
+			log.Println("connecting to org:", name)
+
+			connect := connectDB
+			if readOnlyCommands[cCtx.Args().First()] {
+				connect = connectReadDB
+			}
+
+			orgDB, err := connect(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			db = orgDB
+			svc = service.New(store.NewSQL(orgDB))
+			return nil
+		},
+		Commands: []*cli.Command{
+			runMigrations(ctx),
+			newCreateOrgCommand(ctx),
+			newDeleteOrgCommand(ctx),
+			newCreateCustomerCommand(&svc),
+			newCreateProductCommand(&svc),
+			newCreateOrderCommand(&svc),
+			newUpdateCustomerCommand(&svc),
+			newUpdateProductCommand(&svc),
+			newUpdateOrderCommand(&svc),
+			newDeleteCustomerCommand(&svc),
+			newDeleteProductCommand(&svc),
+			newDeleteOrderCommand(&svc),
+			newGetCustomerCommand(&svc),
+			newGetProductCommand(&svc),
+			newGetOrderCommand(&svc),
+			newShowCustomerCommand(&svc, ctx),
+			newShowProductCommand(&svc, ctx),
+			newShowOrderCommand(&svc, ctx),
+		},
+	}
+
+	setOnUsageError(app)
+	if err := app.Run(os.Args); err != nil {
+		log.Print(err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+// setOnUsageError makes the flag parsing errors of app and its commands usage errors, so that
+// a bad flag exits with exitUsage like any other invalid input.
+func setOnUsageError(app *cli.App) {
+	onUsageError := func(cCtx *cli.Context, err error, isSubcommand bool) error {
+		return usageError(err.Error())
+	}
+	app.OnUsageError = onUsageError
+	for _, cmd := range app.Commands {
+		cmd.OnUsageError = onUsageError
+	}
 }
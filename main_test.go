@@ -3,13 +3,27 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/urfave/cli/v2"
+	"github.com/vivek-shah-13/store/internal/archive"
+	"github.com/vivek-shah-13/store/internal/audit"
+	"github.com/vivek-shah-13/store/internal/config"
+	"github.com/vivek-shah-13/store/internal/database"
+	"github.com/vivek-shah-13/store/internal/domain"
 	"github.com/vivek-shah-13/store/internal/migration"
+	"github.com/vivek-shah-13/store/internal/org"
+	"github.com/vivek-shah-13/store/internal/service"
+	"github.com/vivek-shah-13/store/internal/store"
 	"gotest.tools/v3/assert"
 )
 
@@ -17,18 +31,68 @@ var (
 	dbName = "google"
 )
 
-func tearDownCustomers() error {
-	db, err := connectDB(dbName)
+// TestMain points the tests at the database configured by the environment, so that running
+// with STORE_DB_DRIVER=sqlite runs the whole suite against local files.
+func TestMain(m *testing.M) {
+	cfg, err := config.Load(config.DefaultConfigPath, false, os.LookupEnv)
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
-	defer db.Close()
 
-	_, err = db.Exec("DELETE FROM Customers")
+	var cleanup func()
+	if _, ok := os.LookupEnv(config.EnvDBPath); cfg.Database.Driver == config.DriverSQLite && !ok {
+		dir, err := os.MkdirTemp("", "store-test")
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Database.Path = dir
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+	catalog = org.NewCatalog(cfg, os.LookupEnv)
+
+	if cfg.Database.Driver == config.DriverSQLite {
+		if err := setUpSQLiteOrgs(context.Background(), "default", "google", "microsoft"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	code := m.Run()
+	if cleanup != nil {
+		cleanup()
+	}
+	os.Exit(code)
+}
+
+// setUpSQLiteOrgs creates and migrates the sqlite databases of orgs which do not exist yet.
+func setUpSQLiteOrgs(ctx context.Context, names ...string) error {
+	runner := NewMigrationRunner(migrationsPath)
+	for _, name := range names {
+		conn, err := catalog.Connection(name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(database.SQLiteFile(conn)); err == nil {
+			continue
+		}
+
+		if err := database.Create(ctx, conn); err != nil {
+			return err
+		}
+		if err := runner.runOrg(ctx, &migration.OrgMigrationState{Name: name, LastRanMigrationID: -1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tearDownCustomers() error {
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec("ALTER TABLE Customers AUTO_INCREMENT=1")
+	defer db.Close()
+
+	err = database.ResetTable(context.Background(), db, "Customers")
 	if err != nil {
 		return err
 	}
@@ -36,17 +100,13 @@ func tearDownCustomers() error {
 }
 
 func tearDownProducts() error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	_, err = db.Exec("DELETE FROM Products")
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec("ALTER TABLE Products AUTO_INCREMENT=1")
+	err = database.ResetTable(context.Background(), db, "Products")
 	if err != nil {
 		return err
 	}
@@ -54,17 +114,13 @@ func tearDownProducts() error {
 }
 
 func tearDownOrders() error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	_, err = db.Exec("DELETE FROM Orders")
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec("ALTER TABLE Orders AUTO_INCREMENT=1")
+	err = database.ResetTable(context.Background(), db, "Orders")
 	if err != nil {
 		return err
 	}
@@ -80,15 +136,16 @@ func tearDownOrders() error {
 }
 
 func createCustomersData(args [][]string) error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newCreateCustomerCommand(&db),
+			newCreateCustomerCommand(&svc),
 		},
 	}
 
@@ -106,7 +163,7 @@ func createCustomersDataV2(t *testing.T, args [][]string) {
 }
 
 func createCustomersDataV3(args [][]string) error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
@@ -122,15 +179,16 @@ func createCustomersDataV3(args [][]string) error {
 }
 
 func createProductsData(args [][]string) error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newCreateProductCommand(&db),
+			newCreateProductCommand(&svc),
 		},
 	}
 
@@ -148,7 +206,7 @@ func createProductsDataV2(t *testing.T, args [][]any) {
 }
 
 func createProductsDataV3(args [][]any) error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
@@ -173,15 +231,16 @@ func createOrdersData(customers [][]string, products [][]string, orders [][]stri
 		return err
 	}
 
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newCreateOrderCommand(&db),
+			newCreateOrderCommand(&svc),
 		},
 	}
 
@@ -201,7 +260,7 @@ func createOrdersDataV2(t *testing.T, args [][]int) {
 }
 
 func createOrdersDataV3(args [][]int) error {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		return err
 	}
@@ -274,7 +333,7 @@ func TestCreateCustomer_withValidInput_entersDatabaseCorrectly(t *testing.T) {
 	assert.NilError(t, err)
 	err = createCustomersData([][]string{{"store", "create-customer", "vivek.s@outreach.io", "WA"}})
 	assert.NilError(t, err)
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	assert.NilError(t, err)
 	defer db.Close()
 
@@ -292,7 +351,7 @@ func TestCreateCustomer_withValidInput_entersDatabaseCorrectly(t *testing.T) {
 }
 
 func TestCreateMultipleCustomer_withValidInput_entersDatabaseCorrectly(t *testing.T) {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -316,8 +375,8 @@ func TestCreateMultipleCustomer_withValidInput_entersDatabaseCorrectly(t *testin
 
 }
 
-func Example_CreateCustomer_hasCorrectPrintOutput() {
-	printCustomer(os.Stdout, &Customer{1, "vivek.s@outreach.io", "WA"})
+func Example_createCustomer_hasCorrectPrintOutput() {
+	printCustomer(os.Stdout, &domain.Customer{ID: 1, Email: "vivek.s@outreach.io", State: "WA"})
 
 	//Output:
 	//ID  |Email                                              |State |
@@ -325,9 +384,9 @@ func Example_CreateCustomer_hasCorrectPrintOutput() {
 
 }
 
-func Example_CreateMultipleCustomer_hasCorrectPrintOutput() {
-	printCustomer(os.Stdout, &Customer{1, "vivek.s@outreach.io", "WA"})
-	printCustomer(os.Stdout, &Customer{2, "v.s@verylonglonglonglongemail.com", "MN"})
+func Example_createMultipleCustomer_hasCorrectPrintOutput() {
+	printCustomer(os.Stdout, &domain.Customer{ID: 1, Email: "vivek.s@outreach.io", State: "WA"})
+	printCustomer(os.Stdout, &domain.Customer{ID: 2, Email: "v.s@verylonglonglonglongemail.com", State: "MN"})
 	//Output:
 	//ID  |Email                                              |State |
 	//1   |vivek.s@outreach.io                                |WA    |
@@ -371,7 +430,7 @@ func TestCreateProduct_noPrice(t *testing.T) {
 }
 
 func TestCreateProduct_validInputNoSku(t *testing.T) {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	assert.NilError(t, err)
 	defer db.Close()
 
@@ -395,7 +454,7 @@ func TestCreateProduct_validInputNoSku(t *testing.T) {
 }
 
 func TestCreateProduct_validInputWithSku(t *testing.T) {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	assert.NilError(t, err)
 	defer db.Close()
 
@@ -419,7 +478,7 @@ func TestCreateProduct_validInputWithSku(t *testing.T) {
 }
 
 func TestCreateProduct_decimalPrice(t *testing.T) {
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	assert.NilError(t, err)
 	defer db.Close()
 
@@ -442,8 +501,8 @@ func TestCreateProduct_decimalPrice(t *testing.T) {
 	assert.Equal(t, sku, "abcde")
 }
 
-func Example_CreateProduct_CorrectOutputWithSku() {
-	printProduct(os.Stdout, &Product{1, "laptop", 0, sql.NullString{String: "abcde", Valid: true}})
+func Example_createProduct_CorrectOutputWithSku() {
+	printProduct(os.Stdout, &domain.Product{ID: 1, Name: "laptop", Price: 0, Sku: domain.OptionalString("abcde")})
 
 	//Output:
 	//ID  |Name                      |Price         |Sku                       |
@@ -451,8 +510,8 @@ func Example_CreateProduct_CorrectOutputWithSku() {
 
 }
 
-func ExampleCreateProductCorrectOutputWithoutSku() {
-	printProduct(os.Stdout, &Product{1, "laptop", 0, sql.NullString{String: "", Valid: false}})
+func Example_createProductCorrectOutputWithoutSku() {
+	printProduct(os.Stdout, &domain.Product{ID: 1, Name: "laptop", Price: 0})
 
 	//Output:
 	//ID  |Name                      |Price         |Sku                       |
@@ -504,7 +563,7 @@ func TestCreateNewOrderWithValidInputs(t *testing.T) {
 	err := tearDownOrders()
 	assert.NilError(t, err)
 
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	assert.NilError(t, err)
 	defer db.Close()
 
@@ -543,8 +602,8 @@ func TestCreateNewOrder_ValidInputs_ProductForeignKey_Error(t *testing.T) {
 	assert.ErrorContains(t, err, "Product ID does not exist")
 }
 
-func Example_CreateOrder_WithCorrectOutput() {
-	printOrder(os.Stdout, &Order{1, sql.NullString{}, 1, 1})
+func Example_createOrder_WithCorrectOutput() {
+	printOrder(os.Stdout, &domain.Order{ID: 1, CustomerID: 1, ProductID: 1})
 
 	//Output:
 	//OrderID    |ProductID    |CustomerID    |
@@ -575,23 +634,24 @@ func Example_CreateOrder_WithCorrectOutput() {
 // 1. test output of show-products no flag
 // 2. test output of show-products with name flag
 
-func Example_ShowProductsNoFlag() {
+func Example_showProductsNoFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownProducts()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
 	createProductsDataV3([][]any{{"laptop", 25, "abcde"}, {"book", 12.5, "bcd"}})
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowProductCommand(&db, ctx),
+			newShowProductCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-products"})
@@ -599,9 +659,10 @@ func Example_ShowProductsNoFlag() {
 	//ID  |Name                      |Price         |Sku                       |
 	//1   |laptop                    |25.00         |abcde                     |
 	//2   |book                      |12.50         |bcd                       |
+	//Showing 2 of 2 products
 }
 
-func Example_ShowProductNameFlag() {
+func Example_showProductNameFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
@@ -609,22 +670,24 @@ func Example_ShowProductNameFlag() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
 	createProductsDataV3([][]any{{"laptop", 25, "abcde"}, {"book", 12.5, "bcd"}})
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowProductCommand(&db, ctx),
+			newShowProductCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-products", "--name=laptop"})
 	//Output:
 	//ID  |Name                      |Price         |Sku                       |
 	//1   |laptop                    |25.00         |abcde                     |
+	//Showing 1 of 1 products
 
 }
 
@@ -657,23 +720,24 @@ func Example_ShowProductNameFlag() {
 // 3. test output of show-users with state param
 // 4. test output of show-users with both email and state param
 
-func Example_ShowCustomersNoFlag() {
+func Example_showCustomersNoFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownCustomers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
 	createCustomersDataV3([][]string{{"vivek.shah@oureach.io", "WA"}, {"vivek.s@outlook.com", "MN"}})
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowCustomerCommand(&db, ctx),
+			newShowCustomerCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-customers"})
@@ -681,81 +745,92 @@ func Example_ShowCustomersNoFlag() {
 	//ID  |Email                                              |State |
 	//1   |vivek.shah@oureach.io                              |WA    |
 	//2   |vivek.s@outlook.com                                |MN    |
+	//Showing 2 of 2 customers
 }
 
-func Example_ShowCustomersStateFlag() {
+func Example_showCustomersStateFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownCustomers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
 	createCustomersDataV3([][]string{{"vivek.shah@oureach.io", "WA"}, {"vivek.s@outlook.com", "MN"}})
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowCustomerCommand(&db, ctx),
+			newShowCustomerCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-customers", "--state=WA"})
+	app.Run([]string{"store", "show-customers", "--state", "wa"})
 	//Output:
 	//ID  |Email                                              |State |
 	//1   |vivek.shah@oureach.io                              |WA    |
+	//Showing 1 of 1 customers
+	//ID  |Email                                              |State |
+	//1   |vivek.shah@oureach.io                              |WA    |
+	//Showing 1 of 1 customers
 }
 
-func Example_ShowCustomersEmailFlag() {
+func Example_showCustomersEmailFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownCustomers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
 	createCustomersDataV3([][]string{{"vivek.shah@outreach.io", "WA"}, {"vivek.s@outlook.com", "MN"}})
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowCustomerCommand(&db, ctx),
+			newShowCustomerCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-customers", "--email=outreach"})
 	//Output:
 	//ID  |Email                                              |State |
 	//1   |vivek.shah@outreach.io                             |WA    |
+	//Showing 1 of 1 customers
 }
 
-func Example_ShowCustomers_EmailFlag_StateFlag() {
+func Example_showCustomers_EmailFlag_StateFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownCustomers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
 	createCustomersDataV3([][]string{{"vivek.shah@outreach.io", "WA"}, {"vivek.s@outlook.com", "MN"}, {"v.s@outreach.io", "WA"}})
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowCustomerCommand(&db, ctx),
+			newShowCustomerCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-customers", "-state=WA", "--email=vivek"})
 	//Output:
 	//ID  |Email                                              |State |
 	//1   |vivek.shah@outreach.io                             |WA    |
+	//Showing 1 of 1 customers
 }
 
 /*
@@ -792,14 +867,14 @@ Examples:
 // 3. show-orders with product-id flag
 // 4. show-orders with both flags
 
-func Example_ShowOrdersNoFlag() {
+func Example_showOrdersNoFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownOrders()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -807,9 +882,10 @@ func Example_ShowOrdersNoFlag() {
 
 	createOrdersDataV3([][]int{{1, 1}, {2, 2}})
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowOrderCommand(&db, ctx),
+			newShowOrderCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-orders"})
@@ -817,16 +893,17 @@ func Example_ShowOrdersNoFlag() {
 	//OrderID    |ProductID    |CustomerID    |
 	//1          |1            |1             |
 	//2          |2            |2             |
+	//Showing 2 of 2 orders
 }
 
-func Example_ShowOrdersCustomerIDFlag() {
+func Example_showOrdersCustomerIDFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownOrders()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -834,26 +911,28 @@ func Example_ShowOrdersCustomerIDFlag() {
 
 	createOrdersDataV3([][]int{{1, 1}, {2, 2}})
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowOrderCommand(&db, ctx),
+			newShowOrderCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-orders", "--customer-id=2"})
 	//Output:
 	//OrderID    |ProductID    |CustomerID    |
 	//2          |2            |2             |
+	//Showing 1 of 1 orders
 
 }
 
-func Example_ShowOrdersProductIDFlag() {
+func Example_showOrdersProductIDFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownOrders()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -861,26 +940,28 @@ func Example_ShowOrdersProductIDFlag() {
 
 	createOrdersDataV3([][]int{{1, 1}, {2, 2}})
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowOrderCommand(&db, ctx),
+			newShowOrderCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-orders", "--product-id=1"})
 	//Output:
 	//OrderID    |ProductID    |CustomerID    |
 	//1          |1            |1             |
+	//Showing 1 of 1 orders
 
 }
 
-func Example_ShowOrdersProductIDFlag_CustomerIDFlag() {
+func Example_showOrdersProductIDFlag_CustomerIDFlag() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	err := tearDownOrders()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := connectDB(dbName)
+	db, err := connectDB(context.Background(), dbName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -888,15 +969,17 @@ func Example_ShowOrdersProductIDFlag_CustomerIDFlag() {
 
 	createOrdersDataV3([][]int{{1, 1}, {2, 2}, {1, 2}})
 
+	svc := service.New(store.NewSQL(db))
 	app := &cli.App{
 		Commands: []*cli.Command{
-			newShowOrderCommand(&db, ctx),
+			newShowOrderCommand(&svc, ctx),
 		},
 	}
 	app.Run([]string{"store", "show-orders", "-product-id=2", "--customer-id=1"})
 	//Output:
 	//OrderID    |ProductID    |CustomerID    |
 	//3          |2            |1             |
+	//Showing 1 of 1 orders
 
 }
 
@@ -920,19 +1003,17 @@ func TestMigrations(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 	migration.SaveMigrationState(ctx, state, migration.DefaultMigrationStatePath)
-	db, err := connectDB("microsoft")
+	db, err := connectDB(context.Background(), "microsoft")
 	assert.NilError(t, err)
 	defer db.Close()
 
-	_, err = db.Exec("DROP TABLE IF EXISTS Orders, Products, Customers")
-	assert.NilError(t, err)
+	dropTables(t, db)
 
-	db, err = connectDB("google")
+	db, err = connectDB(context.Background(), "google")
 	assert.NilError(t, err)
 	defer db.Close()
 
-	_, err = db.Exec("DROP TABLE IF EXISTS Orders, Products, Customers")
-	assert.NilError(t, err)
+	dropTables(t, db)
 
 	app := &cli.App{
 		Commands: []*cli.Command{
@@ -940,12 +1021,12 @@ func TestMigrations(t *testing.T) {
 		},
 	}
 	app.Run([]string{"store", "run-migrations"})
-	db, err = connectDB("microsoft")
+	db, err = connectDB(context.Background(), "microsoft")
 	assert.NilError(t, err)
 	defer db.Close()
 
 	QueryRows(db, t)
-	db, err = connectDB("google")
+	db, err = connectDB(context.Background(), "google")
 	assert.NilError(t, err)
 	defer db.Close()
 
@@ -953,6 +1034,485 @@ func TestMigrations(t *testing.T) {
 
 }
 
+func TestSelectMigrations_prefersDialectVariants(t *testing.T) {
+	files := []string{
+		"migrations/addProductSku_0001.sql",
+		"migrations/initial_0000.sqlite.sql",
+		"migrations/initial_0000.sql",
+		"migrations/example_0002.mysql.sql",
+		"migrations/example_0002.sql",
+	}
+
+	selected, err := selectMigrations(files, "sqlite")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, selected, []string{
+		"migrations/initial_0000.sqlite.sql",
+		"migrations/addProductSku_0001.sql",
+		"migrations/example_0002.sql",
+	})
+
+	selected, err = selectMigrations(files, "mysql")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, selected, []string{
+		"migrations/initial_0000.sql",
+		"migrations/addProductSku_0001.sql",
+		"migrations/example_0002.mysql.sql",
+	})
+
+	_, err = selectMigrations([]string{"migrations/initial_0000.sqlite.sql"}, "mysql")
+	assert.ErrorContains(t, err, "migration 0 has no file for dialect mysql")
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{usageError("Must specify email and state"), exitUsage},
+		{domain.Invalid("state", "State length must be 2"), exitUsage},
+		{fmt.Errorf("create-org: %w", org.ErrInvalidName), exitUsage},
+		{domain.ErrCustomerNotFound, exitNotFound},
+		{fmt.Errorf("%w: unknown org", org.ErrUnknownOrg), exitNotFound},
+		{database.ErrTLSHandshake, exitUnavailable},
+		{errors.New("boom"), exitError},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, exitCode(test.err), test.want, test.err.Error())
+	}
+}
+
+func TestSetOnUsageError_badFlags_exitWithUsage(t *testing.T) {
+	svc := service.New(store.NewMemory())
+	app := &cli.App{
+		Flags:    []cli.Flag{&cli.StringFlag{Name: "org"}},
+		Commands: []*cli.Command{newShowOrderCommand(&svc, context.Background())},
+	}
+	setOnUsageError(app)
+
+	for _, args := range [][]string{
+		{"store", "show-orders", "--limit", "abc"},
+		{"store", "show-orders", "--unknown"},
+		{"store", "--unknown", "show-orders"},
+	} {
+		err := app.Run(args)
+		assert.Assert(t, err != nil, args)
+		assert.Equal(t, exitCode(err), exitUsage, err.Error())
+	}
+}
+
+func TestCreateNewOrder_missingCustomer_returnsTypedError(t *testing.T) {
+	svc := service.New(store.NewMemory())
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newCreateOrderCommand(&svc),
+		},
+	}
+
+	err := app.Run([]string{"store", "create-order", "1", "1"})
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound), err)
+	assert.Equal(t, exitCode(err), exitNotFound)
+}
+
+func Example_updateCustomer_printsTheUpdatedCustomer() {
+	svc := service.New(store.NewMemory())
+	if _, err := svc.CreateCustomer(context.Background(), "vivek.s@outreach.io", "WA"); err != nil {
+		log.Fatal(err)
+	}
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newUpdateCustomerCommand(&svc),
+		},
+	}
+	app.Run([]string{"store", "update-customer", "--state=ca", "1"})
+	//Output:
+	//ID  |Email                                              |State |
+	//1   |vivek.s@outreach.io                                |CA    |
+}
+
+func TestUpdateCommands_returnErrors(t *testing.T) {
+	svc := service.New(store.NewMemory())
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newUpdateCustomerCommand(&svc),
+			newUpdateProductCommand(&svc),
+			newUpdateOrderCommand(&svc),
+		},
+	}
+
+	err := app.Run([]string{"store", "update-customer", "1"})
+	assert.ErrorContains(t, err, "Must specify at least one field to update")
+	err = app.Run([]string{"store", "update-product", "--name=laptop"})
+	assert.ErrorContains(t, err, "Must specify product_id")
+	err = app.Run([]string{"store", "update-product", "--price=abc", "1"})
+	assert.ErrorContains(t, err, "Price must be a number")
+	err = app.Run([]string{"store", "update-order", "--customer-id=1", "1"})
+	assert.Assert(t, errors.Is(err, domain.ErrOrderNotFound), err)
+}
+
+// newDeleteTestApp returns an app running the delete commands against an in memory org with one
+// customer, one product and two orders of it, reading confirmations from input.
+func newDeleteTestApp(t *testing.T, input string) (*cli.App, *service.Service) {
+	ctx := context.Background()
+	svc := service.New(store.NewMemory())
+	_, err := svc.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	assert.NilError(t, err)
+	_, err = svc.CreateProduct(ctx, "laptop", 25, "abcde")
+	assert.NilError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = svc.CreateOrder(ctx, 1, 1)
+		assert.NilError(t, err)
+	}
+
+	app := &cli.App{
+		Reader: strings.NewReader(input),
+		Commands: []*cli.Command{
+			newDeleteCustomerCommand(&svc),
+			newDeleteProductCommand(&svc),
+			newDeleteOrderCommand(&svc),
+		},
+	}
+	return app, svc
+}
+
+func Example_deleteCustomer_dryRun() {
+	svc := service.New(store.NewMemory())
+	svc.CreateCustomer(context.Background(), "vivek.s@outreach.io", "WA")
+	app := &cli.App{
+		Flags:     []cli.Flag{newOutputFlag()},
+		ErrWriter: io.Discard,
+		Commands: []*cli.Command{
+			newDeleteCustomerCommand(&svc),
+		},
+	}
+	app.Run([]string{"store", "delete-customer", "--dry-run", "1"})
+	app.Run([]string{"store", "--output=ndjson", "delete-customer", "--dry-run", "1"})
+	//Output:
+	//ID  |Email                                              |State |
+	//1   |vivek.s@outreach.io                                |WA    |
+	//Deleting customer 1 also deletes 0 order(s).
+	//dry run, nothing was deleted
+	//{"id":1,"email":"vivek.s@outreach.io","state":"WA"}
+}
+
+func TestDeleteCustomer_withYes_deletesTheCustomerAndTheirOrders(t *testing.T) {
+	app, svc := newDeleteTestApp(t, "")
+
+	assert.NilError(t, app.Run([]string{"store", "delete-customer", "--yes", "1"}))
+
+	orders, err := svc.ListOrders(context.Background(), store.OrderFilter{}, store.ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, orders.Total, 0)
+}
+
+func TestDeleteProduct_withoutConfirmation_isAborted(t *testing.T) {
+	app, svc := newDeleteTestApp(t, "n\n")
+
+	err := app.Run([]string{"store", "delete-product", "1"})
+	assert.Assert(t, errors.Is(err, errDeleteAborted), err)
+
+	_, orders, err := svc.PreviewDeleteProduct(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, orders, 2)
+}
+
+func TestDeleteOrder_confirmedInteractively_deletesTheOrder(t *testing.T) {
+	app, svc := newDeleteTestApp(t, "y\n")
+
+	assert.NilError(t, app.Run([]string{"store", "delete-order", "2"}))
+
+	_, err := svc.GetOrder(context.Background(), 2)
+	assert.Assert(t, errors.Is(err, domain.ErrOrderNotFound), err)
+	err = app.Run([]string{"store", "delete-order", "--yes", "2"})
+	assert.Assert(t, errors.Is(err, domain.ErrOrderNotFound), err)
+}
+
+func Example_getOrder_showsTheCustomerAndProduct() {
+	ctx := context.Background()
+	svc := service.New(store.NewMemory())
+	svc.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	svc.CreateProduct(ctx, "laptop", 25, "abcde")
+	svc.CreateOrder(ctx, 1, 1)
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newGetOrderCommand(&svc),
+		},
+	}
+	app.Run([]string{"store", "get-order", "1"})
+	//Output:
+	//OrderID    |ProductID    |CustomerID    |
+	//1          |1            |1             |
+	//
+	//Customer:
+	//ID  |Email                                              |State |
+	//1   |vivek.s@outreach.io                                |WA    |
+	//
+	//Product:
+	//ID  |Name                      |Price         |Sku                       |
+	//1   |laptop                    |25.00         |abcde                     |
+}
+
+func Example_getCustomer_byEmail_showsTheirOrders() {
+	ctx := context.Background()
+	svc := service.New(store.NewMemory())
+	svc.CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	svc.CreateProduct(ctx, "laptop", 25, "abcde")
+	svc.CreateOrder(ctx, 1, 1)
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newGetCustomerCommand(&svc),
+		},
+	}
+	app.Run([]string{"store", "get-customer", "--email=vivek.s@outreach.io"})
+	//Output:
+	//ID  |Email                                              |State |
+	//1   |vivek.s@outreach.io                                |WA    |
+	//
+	//Orders:
+	//OrderID    |ProductID    |CustomerID    |
+	//1          |1            |1             |
+}
+
+func TestGetCommands_returnErrors(t *testing.T) {
+	svc := service.New(store.NewMemory())
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newGetCustomerCommand(&svc),
+			newGetProductCommand(&svc),
+			newGetOrderCommand(&svc),
+		},
+	}
+
+	err := app.Run([]string{"store", "get-customer", "1"})
+	assert.Assert(t, errors.Is(err, domain.ErrCustomerNotFound), err)
+	err = app.Run([]string{"store", "get-product", "--sku=abcde"})
+	assert.Assert(t, errors.Is(err, domain.ErrProductNotFound), err)
+	err = app.Run([]string{"store", "get-product", "--sku=abcde", "1"})
+	assert.ErrorContains(t, err, "Must specify either product_id or --sku, not both")
+	err = app.Run([]string{"store", "get-order"})
+	assert.ErrorContains(t, err, "Must specify order_id")
+}
+
+func Example_showProducts_nameMatchModes() {
+	ctx := context.Background()
+	svc := service.New(store.NewMemory())
+	svc.CreateProduct(ctx, "Milk", 4, "")
+	svc.CreateProduct(ctx, "Cream", 6, "")
+	svc.CreateProduct(ctx, "Soy Milk", 5, "")
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newShowProductCommand(&svc, ctx),
+		},
+	}
+	app.Run([]string{"store", "show-products", "--name=M%", "--name-match=like"})
+	app.Run([]string{"store", "show-products", "--name=M%"})
+	//Output:
+	//ID  |Name                      |Price         |Sku                       |
+	//1   |Milk                      |4.00          |                          |
+	//Showing 1 of 1 products
+	//ID  |Name                      |Price         |Sku                       |
+	//Showing 0 of 0 products
+}
+
+func TestShowCommands_invalidPaging_returnsUsageError(t *testing.T) {
+	svc := service.New(store.NewMemory())
+	app := &cli.App{
+		Commands: []*cli.Command{
+			newShowOrderCommand(&svc, context.Background()),
+		},
+	}
+
+	err := app.Run([]string{"store", "show-orders", "--sort=price"})
+	assert.ErrorContains(t, err, "Sort field must be one of id, created_at, customer_id, product_id")
+	assert.Equal(t, exitCode(err), exitUsage)
+	err = app.Run([]string{"store", "show-orders", "--sort=customer_id", "--after=3"})
+	assert.ErrorContains(t, err, "After only works when sorting by id")
+	assert.Equal(t, exitCode(err), exitUsage)
+	err = app.Run([]string{"store", "show-orders", "--expand", "--count"})
+	assert.ErrorContains(t, err, "Must not specify --expand with --count, --group-by or an aggregate")
+	assert.Equal(t, exitCode(err), exitUsage)
+	app.Commands = []*cli.Command{newShowCustomerCommand(&svc, context.Background())}
+	err = app.Run([]string{"store", "show-customers", "--email=(", "--email-match=regex"})
+	assert.ErrorContains(t, err, "Pattern must be a valid regular expression")
+	assert.Equal(t, exitCode(err), exitUsage)
+	err = app.Run([]string{"store", "show-customers", "--states=WA,XX"})
+	assert.ErrorContains(t, err, "State must be a valid U.S. State or Territory")
+	assert.Equal(t, exitCode(err), exitUsage)
+
+	app.Commands = []*cli.Command{newShowProductCommand(&svc, context.Background())}
+	err = app.Run([]string{"store", "show-products", "--has-sku", "--no-sku"})
+	assert.Equal(t, exitCode(err), exitUsage, err)
+	err = app.Run([]string{"store", "show-products", "--where", "price > 10 and colour = red"})
+	assert.ErrorContains(t, err, `Invalid filter expression at column 16: unknown field "colour"`)
+	assert.Equal(t, exitCode(err), exitUsage)
+	err = app.Run([]string{"store", "show-products", "--group-by=sku", "--sort=price"})
+	assert.ErrorContains(t, err, "Must not specify --sort with --count, --group-by or an aggregate")
+	assert.Equal(t, exitCode(err), exitUsage)
+	err = app.Run([]string{"store", "show-products", "--sum=name"})
+	assert.ErrorContains(t, err, "Sum field must be one of price")
+	assert.Equal(t, exitCode(err), exitUsage)
+}
+
+// newOutputTestApp returns an app over two customers, two products and three orders created in
+// June 2023, shared by the examples of the show commands and their flags.
+func newOutputTestApp() *cli.App {
+	ctx := context.Background()
+	stores := store.NewMemory()
+	svc := service.New(stores)
+	must := func(_ any, err error) {
+		if err != nil {
+			panic(err)
+		}
+	}
+	must(svc.CreateCustomer(ctx, "vivek.s@outreach.io", "WA"))
+	must(svc.CreateCustomer(ctx, "v.s@outreach.io", "CA"))
+	must(svc.CreateProduct(ctx, "laptop", 25, "abcde"))
+	must(svc.CreateProduct(ctx, "pen", 2, ""))
+	for _, o := range []struct{ day, customerID, productID int }{{1, 1, 1}, {15, 1, 2}, {30, 2, 1}} {
+		createdAt := time.Date(2023, 6, o.day, 12, 0, 0, 0, time.UTC)
+		must(nil, stores.Orders.Create(ctx, &domain.Order{CreatedAt: &createdAt, CustomerID: o.customerID, ProductID: o.productID}))
+	}
+	return &cli.App{
+		Flags: []cli.Flag{newOutputFlag()},
+		Commands: []*cli.Command{
+			newShowCustomerCommand(&svc, ctx),
+			newShowProductCommand(&svc, ctx),
+			newShowOrderCommand(&svc, ctx),
+			newGetCustomerCommand(&svc),
+		},
+	}
+}
+
+func Example_showProducts_outputJSON() {
+	newOutputTestApp().Run([]string{"store", "--output=json", "show-products"})
+	//Output:
+	//{
+	//   "total": 2,
+	//   "records": [
+	//     {
+	//       "id": 1,
+	//       "name": "laptop",
+	//       "price": 25,
+	//       "sku": "abcde"
+	//     },
+	//     {
+	//       "id": 2,
+	//       "name": "pen",
+	//       "price": 2,
+	//       "sku": null
+	//     }
+	//   ]
+	//}
+}
+
+func Example_showProducts_outputCSV() {
+	newOutputTestApp().Run([]string{"store", "-o", "csv", "show-products"})
+	//Output:
+	//id,name,price,sku
+	//1,laptop,25.00,abcde
+	//2,pen,2.00,
+}
+
+func Example_getCustomer_outputYAML() {
+	newOutputTestApp().Run([]string{"store", "-o", "yaml", "get-customer", "1"})
+	//Output:
+	//customer:
+	//   id: 1
+	//   email: vivek.s@outreach.io
+	//   state: WA
+	//orders:
+	//   - id: 1
+	//     created_at: 2023-06-01T12:00:00Z
+	//     customer_id: 1
+	//     product_id: 1
+	//   - id: 2
+	//     created_at: 2023-06-15T12:00:00Z
+	//     customer_id: 1
+	//     product_id: 2
+}
+
+func Example_showProducts_sortedAndPaged() {
+	newOutputTestApp().Run([]string{"store", "show-products", "--sort=price:desc", "--limit=1", "--offset=1"})
+	//Output:
+	//ID  |Name                      |Price         |Sku                       |
+	//2   |pen                       |2.00          |                          |
+	//Showing 1 of 2 products
+}
+
+func Example_showProducts_byPriceAndSku() {
+	newOutputTestApp().Run([]string{"store", "show-products", "--min-price=2", "--max-price=30", "--no-sku"})
+	//Output:
+	//ID  |Name                      |Price         |Sku                       |
+	//2   |pen                       |2.00          |                          |
+	//Showing 1 of 1 products
+}
+
+func Example_showOrders_filteredByCreationDate() {
+	newOutputTestApp().Run([]string{"store", "show-orders", "--created-from=2023-06-01", "--created-to=2023-06-15", "--customer-id=1,2"})
+	//Output:
+	//OrderID    |ProductID    |CustomerID    |
+	//1          |1            |1             |
+	//2          |2            |1             |
+	//Showing 2 of 2 orders
+}
+
+func Example_showOrders_whereExpression() {
+	newOutputTestApp().Run([]string{"store", "show-orders", "--where", "product.price > 10 and customer.state in (WA, CA)"})
+	//Output:
+	//OrderID    |ProductID    |CustomerID    |
+	//1          |1            |1             |
+	//3          |1            |2             |
+	//Showing 2 of 2 orders
+}
+
+func Example_showOrders_groupedByState() {
+	app := newOutputTestApp()
+	app.Run([]string{"store", "show-orders", "--group-by=customer.state", "--count", "--sum=product.price", "--avg=product.price"})
+	app.Run([]string{"store", "show-customers", "--count"})
+	app.Run([]string{"store", "--output=csv", "show-orders", "--group-by=product_id"})
+	//Output:
+	//customer.state |count         |sum(product.price) |avg(product.price) |
+	//CA             |1             |25.00              |25.00              |
+	//WA             |2             |27.00              |13.50              |
+	//count         |
+	//2             |
+	//product_id,count
+	//1,2
+	//2,1
+}
+
+func Example_showOrders_expanded() {
+	app := newOutputTestApp()
+	app.Run([]string{"store", "show-orders", "--expand"})
+	app.Run([]string{"store", "show-orders", "--expand", "--where", "customer.state = CA"})
+	//Output:
+	//OrderID    |CreatedAt            |CustomerID    |Email                     |State |ProductID    |Name                      |Price         |Sku                       |
+	//1          |2023-06-01T12:00:00Z |1             |vivek.s@outreach.io       |WA    |1            |laptop                    |25.00         |abcde                     |
+	//2          |2023-06-15T12:00:00Z |1             |vivek.s@outreach.io       |WA    |2            |pen                       |2.00          |                          |
+	//3          |2023-06-30T12:00:00Z |2             |v.s@outreach.io           |CA    |1            |laptop                    |25.00         |abcde                     |
+	//Showing 3 of 3 orders
+	//OrderID    |CreatedAt            |CustomerID    |Email                     |State |ProductID    |Name                      |Price         |Sku                       |
+	//3          |2023-06-30T12:00:00Z |2             |v.s@outreach.io           |CA    |1            |laptop                    |25.00         |abcde                     |
+	//Showing 1 of 1 orders
+}
+
+func TestOutput_unsupportedFormat_returnsUsageError(t *testing.T) {
+	app := newOutputTestApp()
+
+	err := app.Run([]string{"store", "-o", "xml", "show-products"})
+	assert.Equal(t, exitCode(err), exitUsage, err)
+	err = app.Run([]string{"store", "-o", "csv", "get-customer", "1"})
+	assert.ErrorContains(t, err, "csv output is not supported by get-customer")
+	assert.Equal(t, exitCode(err), exitUsage)
+}
+
+func dropTables(t *testing.T, db *sql.DB) {
+	for _, table := range []string{"Orders", "Products", "Customers"} {
+		_, err := db.Exec("DROP TABLE IF EXISTS " + table)
+		assert.NilError(t, err)
+	}
+}
+
 func QueryRows(db *sql.DB, t *testing.T) {
 	_, err := db.Query("SELECT * FROM Orders")
 	assert.NilError(t, err)
@@ -989,7 +1549,7 @@ func TestConnectingToDefault(t *testing.T) {
 			org := cCtx.String("org")
 			log.Println("connecting to org:", org)
 
-			orgDB, err := connectDB(org)
+			orgDB, err := connectDB(context.Background(), org)
 			if err != nil {
 				return err
 			}
@@ -1016,7 +1576,7 @@ func TestConnectingToMicrosoft(t *testing.T) {
 			org := cCtx.String("org")
 			log.Println("connecting to org:", org)
 
-			orgDB, err := connectDB(org)
+			orgDB, err := connectDB(context.Background(), org)
 			if err != nil {
 				return err
 			}
@@ -1043,7 +1603,7 @@ func TestConnectingToGoogle(t *testing.T) {
 			org := cCtx.String("org")
 			log.Println("connecting to org:", org)
 
-			orgDB, err := connectDB(org)
+			orgDB, err := connectDB(context.Background(), org)
 			if err != nil {
 				return err
 			}
@@ -1070,7 +1630,7 @@ func TestConnectingToNonExistingDB(t *testing.T) {
 			org := cCtx.String("org")
 			log.Println("connecting to org:", org)
 
-			orgDB, err := connectDB(org)
+			orgDB, err := connectDB(context.Background(), org)
 			if err != nil {
 				return err
 			}
@@ -1079,10 +1639,122 @@ func TestConnectingToNonExistingDB(t *testing.T) {
 			return nil
 		},
 	}
-	assert.ErrorContains(t, app.Run([]string{"store", "--org=abc"}), "Error 1049 (42000): Unknown database 'store_abc'")
+	err := app.Run([]string{"store", "--org=abc"})
+	assert.Assert(t, errors.Is(err, database.ErrDatabaseNotFound), err)
 	_ = db
 }
 
+func TestCreateOrg_failedMigration_dropsTheDatabaseAndKeepsTheState(t *testing.T) {
+	conn, err := catalog.Connection("broken")
+	assert.NilError(t, err)
+	if conn.Driver != config.DriverSQLite {
+		t.Skip("needs STORE_DB_DRIVER=sqlite")
+	}
+
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(dir+"/initial_0000.sql", []byte("CREATE TABLE Customers(ID INT);\nNOT SQL;\n"), 0644))
+	state := &migration.MigrationState{Orgs: []*migration.OrgMigrationState{{Name: "default", LastRanMigrationID: 1}}}
+
+	_, err = createOrg(context.Background(), NewMigrationRunner(dir), state, "broken")
+	assert.ErrorContains(t, err, "failed to migrate org broken")
+	_, statErr := os.Stat(database.SQLiteFile(conn))
+	assert.Assert(t, errors.Is(statErr, os.ErrNotExist), statErr)
+	assert.DeepEqual(t, state.Orgs, []*migration.OrgMigrationState{{Name: "default", LastRanMigrationID: 1}})
+}
+
+func TestCreateOrg_existingOrg_isRejected(t *testing.T) {
+	conn, err := catalog.Connection("acme")
+	assert.NilError(t, err)
+	if conn.Driver != config.DriverSQLite {
+		t.Skip("needs STORE_DB_DRIVER=sqlite")
+	}
+
+	state := &migration.MigrationState{Orgs: []*migration.OrgMigrationState{{Name: "acme", LastRanMigrationID: 1}}}
+	_, err = createOrg(context.Background(), NewMigrationRunner(migrationsPath), state, "acme")
+	assert.ErrorContains(t, err, "org acme already exists")
+	_, statErr := os.Stat(database.SQLiteFile(conn))
+	assert.Assert(t, errors.Is(statErr, os.ErrNotExist), statErr)
+	assert.Equal(t, len(state.Orgs), 1)
+}
+
+func TestDeleteOrg_withoutTheToken_isRefused(t *testing.T) {
+	app := &cli.App{Commands: []*cli.Command{newDeleteOrgCommand(context.Background())}}
+	for _, confirm := range []string{"", orgConfirmationToken("other")} {
+		err := app.Run([]string{"store", "delete-org", "--confirm", confirm, "acme"})
+		assert.ErrorContains(t, err, "re-run with --confirm "+orgConfirmationToken("acme"))
+		assert.Equal(t, exitCode(err), exitUsage)
+	}
+}
+
+func TestDeleteOrg_defaultOrg_isRefused(t *testing.T) {
+	state := &migration.MigrationState{Orgs: []*migration.OrgMigrationState{{Name: org.Default, LastRanMigrationID: 1}}}
+	_, err := deleteOrg(context.Background(), state, org.Default, t.TempDir())
+	assert.ErrorContains(t, err, "the default org cannot be deleted")
+	assert.Equal(t, exitCode(err), exitUsage)
+	assert.Equal(t, len(state.Orgs), 1)
+}
+
+func TestDeleteOrg_archivesUnregistersAndDropsTheOrg(t *testing.T) {
+	conn, err := catalog.Connection("doomed")
+	assert.NilError(t, err)
+	if conn.Driver != config.DriverSQLite {
+		t.Skip("needs STORE_DB_DRIVER=sqlite")
+	}
+
+	// deleteOrg writes the migration state and the audit log to the working directory.
+	migrations, err := filepath.Abs(migrationsPath)
+	assert.NilError(t, err)
+	wd, err := os.Getwd()
+	assert.NilError(t, err)
+	assert.NilError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	ctx := context.Background()
+	state := &migration.MigrationState{Orgs: []*migration.OrgMigrationState{{Name: org.Default, LastRanMigrationID: 1}}}
+	_, err = createOrg(ctx, NewMigrationRunner(migrations), state, "doomed")
+	assert.NilError(t, err)
+	db, err := connectDB(context.Background(), "doomed")
+	assert.NilError(t, err)
+	_, err = service.New(store.NewSQL(db)).CreateCustomer(ctx, "vivek.s@outreach.io", "WA")
+	db.Close()
+	assert.NilError(t, err)
+
+	// A state which cannot be saved leaves the org registered and its database in place.
+	assert.NilError(t, os.Remove(migration.DefaultMigrationStatePath))
+	assert.NilError(t, os.Mkdir(migration.DefaultMigrationStatePath, 0700))
+	_, err = deleteOrg(ctx, state, "doomed", "failed")
+	assert.Assert(t, err != nil)
+	assert.Equal(t, len(state.Orgs), 2)
+	_, err = os.Stat(database.SQLiteFile(conn))
+	assert.NilError(t, err)
+	assert.NilError(t, os.Remove(migration.DefaultMigrationStatePath))
+
+	path, err := deleteOrg(ctx, state, "doomed", "archives")
+	assert.NilError(t, err)
+
+	// The archive holds the org's data, so it was exported before the database was dropped.
+	a, err := archive.Load(path)
+	assert.NilError(t, err)
+	assert.Equal(t, a.Org, "doomed")
+	assert.Equal(t, len(a.Tables["Customers"]), 1)
+	_, statErr := os.Stat(database.SQLiteFile(conn))
+	assert.Assert(t, errors.Is(statErr, os.ErrNotExist), statErr)
+
+	assert.DeepEqual(t, state.Orgs, []*migration.OrgMigrationState{{Name: org.Default, LastRanMigrationID: 1}})
+	saved, err := migration.LoadMigrationState(ctx, migration.DefaultMigrationStatePath)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, saved.Orgs, state.Orgs)
+
+	records, err := os.ReadFile(audit.DefaultAuditLogPath)
+	assert.NilError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(records)), "\n")
+	var record audit.Record
+	assert.NilError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &record))
+	assert.Equal(t, record.Action, "delete-org")
+	assert.Equal(t, record.Org, "doomed")
+	assert.Equal(t, record.Details["archive"], path)
+}
+
 func Test_SuiteOfTests_Microsoft(t *testing.T) {
 	runMigrationsHelper(t)
 	dbName = "microsoft"
@@ -1120,14 +1792,14 @@ func Test_RunSuiteOfTests(t *testing.T) {
 	TestCreateNewOrderWithValidInputs(t)
 	TestCreateNewOrder_ValidInputs_CustomerForeignKey_Error(t)
 	TestCreateNewOrder_ValidInputs_ProductForeignKey_Error(t)
-	Example_ShowProductNameFlag()
-	Example_ShowProductsNoFlag()
-	Example_ShowCustomersNoFlag()
-	Example_ShowCustomersStateFlag()
-	Example_ShowCustomersEmailFlag()
-	Example_ShowCustomers_EmailFlag_StateFlag()
-	Example_ShowOrdersNoFlag()
-	Example_ShowOrdersCustomerIDFlag()
-	Example_ShowOrdersProductIDFlag()
-	Example_ShowOrdersProductIDFlag_CustomerIDFlag()
+	Example_showProductNameFlag()
+	Example_showProductsNoFlag()
+	Example_showCustomersNoFlag()
+	Example_showCustomersStateFlag()
+	Example_showCustomersEmailFlag()
+	Example_showCustomers_EmailFlag_StateFlag()
+	Example_showOrdersNoFlag()
+	Example_showOrdersCustomerIDFlag()
+	Example_showOrdersProductIDFlag()
+	Example_showOrdersProductIDFlag_CustomerIDFlag()
 }
@@ -0,0 +1 @@
+ALTER TABLE Products ADD COLUMN sku VARCHAR(255);
\ No newline at end of file
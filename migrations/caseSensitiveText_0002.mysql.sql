@@ -0,0 +1,2 @@
+ALTER TABLE Customers MODIFY email VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin, MODIFY state VARCHAR(2) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+ALTER TABLE Products MODIFY name VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin, MODIFY sku VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
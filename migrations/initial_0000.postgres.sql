@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS Customers(ID SERIAL PRIMARY KEY, email VARCHAR(255), state VARCHAR(2));
+CREATE TABLE IF NOT EXISTS Products(ID SERIAL PRIMARY KEY, name VARCHAR(255), price NUMERIC(11,2));
+CREATE TABLE IF NOT EXISTS Orders(ID SERIAL PRIMARY KEY, created_at TIMESTAMP, customer_id INT, product_id INT, FOREIGN KEY (customer_id) REFERENCES Customers(ID) ON DELETE CASCADE, FOREIGN KEY (product_id) REFERENCES Products(ID) ON DELETE CASCADE);
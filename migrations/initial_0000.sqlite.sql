@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS Customers(ID INTEGER PRIMARY KEY AUTOINCREMENT, email VARCHAR(255), state VARCHAR(2));
+CREATE TABLE IF NOT EXISTS Products(ID INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(255), price DOUBLE(11,2));
+CREATE TABLE IF NOT EXISTS Orders(ID INTEGER PRIMARY KEY AUTOINCREMENT, created_at DATETIME, customer_id INT, product_id INT, FOREIGN KEY (customer_id) REFERENCES Customers(ID) ON DELETE CASCADE, FOREIGN KEY (product_id) REFERENCES Products(ID) ON DELETE CASCADE);